@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	"github.com/google/uuid"
+	plugin "github.com/parithera/plugin-fastqc/src"
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/qc_cache"
+	"github.com/parithera/plugin-fastqc/src/utils/qc_diff"
+	pluginSettings "github.com/parithera/plugin-fastqc/src/utils/settings"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// usage is printed when the binary is called with an unknown subcommand.
+const usage = `Usage: plugin [command] [options]
+
+Commands:
+  listen   Consume analyses from RabbitMQ and store results in Postgres (default)
+  run      Run the QC on a local folder and print the JSON report
+  watch    Run the QC on every sequencing run landing in the watched folders
+  diff     Compare two QC results, given as analysis IDs or JSON report files
+
+Run "plugin <command> -h" for the options of a command.
+`
+
+// runOptions holds the options of the run subcommand.
+// They can be read from a JSON config file and overridden by flags.
+type runOptions struct {
+	Input     string `json:"input"`
+	Output    string `json:"output"`
+	OutputDir string `json:"output_dir"`
+	// CacheDir enables the cache of the per-file results, see qc_cache.
+	CacheDir      string `json:"cache_dir"`
+	CacheFullHash bool   `json:"cache_full_hash"`
+}
+
+// runCommand executes the QC on a local folder without AMQP or Postgres.
+// It returns the process exit code: 0 on success, 1 if the analysis failed and 2 on invalid usage.
+func runCommand(arguments []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	configPath := flags.String("config", "", "JSON file providing the options, flags take precedence")
+	input := flags.String("input", "", "folder containing the FASTQ files")
+	output := flags.String("output", "", "file to write the JSON report to (defaults to stdout)")
+	outputDir := flags.String("output-dir", "", "folder receiving the FastQC reports (defaults to <input>/fastqc)")
+	cacheDir := flags.String("cache-dir", "", "folder caching the results of the files already analyzed")
+	cacheFullHash := flags.Bool("cache-full-hash", false, "identify cached files by their SHA-256 instead of their size and modification time")
+	if err := flags.Parse(arguments); err != nil {
+		return 2
+	}
+
+	var options runOptions
+	if *configPath != "" {
+		if err := readJSONFile(*configPath, &options); err != nil {
+			slog.Error("failed to read config file", "error", err)
+			return 2
+		}
+	}
+
+	// Only override the config file with flags that were explicitly set.
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "input":
+			options.Input = *input
+		case "output":
+			options.Output = *output
+		case "output-dir":
+			options.OutputDir = *outputDir
+		case "cache-dir":
+			options.CacheDir = *cacheDir
+		case "cache-full-hash":
+			options.CacheFullHash = *cacheFullHash
+		}
+	})
+
+	if options.Input == "" {
+		slog.Error("--input is required")
+		flags.Usage()
+		return 2
+	}
+	info, err := os.Stat(options.Input)
+	if err != nil || !info.IsDir() {
+		slog.Error("input is not a directory", "input", options.Input)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// No database is needed, the plugin only reads the files on disk.
+	if options.OutputDir == "" {
+		options.OutputDir = filepath.Join(options.Input, "fastqc")
+	}
+	var qcCache *qc_cache.Cache
+	if options.CacheDir != "" {
+		qcCache = qc_cache.New(options.CacheDir, options.CacheFullHash)
+	}
+	out := plugin.Start(ctx, options.Input, options.OutputDir, nil, qcCache)
+
+	var writer io.Writer = os.Stdout
+	if options.Output != "" {
+		file, err := os.Create(options.Output)
+		if err != nil {
+			slog.Error("failed to create output file", "error", err)
+			return 1
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	if err := writeJSON(writer, out); err != nil {
+		slog.Error("failed to write report", "error", err)
+		return 1
+	}
+
+	if out.AnalysisInfo.Status != codeclarity.SUCCESS {
+		return 1
+	}
+	return 0
+}
+
+// readJSONFile decodes the options of a subcommand from a JSON file.
+func readJSONFile(path string, options any) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(options); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// defaultReportName is the file written next to the data by the watch subcommand.
+const defaultReportName = "fastqc.json"
+
+// watchOptions holds the options of the watch subcommand.
+// They can be read from a JSON config file and overridden by flags.
+type watchOptions struct {
+	Directories   []string `json:"directories"`
+	StableFor     string   `json:"stable_for"`
+	ReportName    string   `json:"report_name"`
+	RequireMarker bool     `json:"require_marker"`
+	CacheDir      string   `json:"cache_dir"`
+	CacheFullHash bool     `json:"cache_full_hash"`
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// watchCommand watches folders for newly landed sequencing runs and writes a JSON report next to each analyzed folder.
+// Folders that already hold a report are skipped. It returns the process exit code.
+func watchCommand(arguments []string) int {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	configPath := flags.String("config", "", "JSON file providing the options, flags take precedence")
+	var directories stringList
+	flags.Var(&directories, "dir", "folder to watch, can be repeated")
+	stableFor := flags.String("stable-for", "10m", "how long FASTQ files must stay unchanged before a folder is analyzed")
+	requireMarker := flags.Bool("require-marker", false, "also wait for RTAComplete.txt or CopyComplete.txt before analyzing a folder")
+	reportName := flags.String("report-name", defaultReportName, "name of the JSON report written in each analyzed folder")
+	cacheDir := flags.String("cache-dir", "", "folder caching the results of the files already analyzed")
+	cacheFullHash := flags.Bool("cache-full-hash", false, "identify cached files by their SHA-256 instead of their size and modification time")
+	if err := flags.Parse(arguments); err != nil {
+		return 2
+	}
+
+	options := watchOptions{StableFor: *stableFor, ReportName: *reportName}
+	if *configPath != "" {
+		if err := readJSONFile(*configPath, &options); err != nil {
+			slog.Error("failed to read config file", "error", err)
+			return 2
+		}
+	}
+
+	// Only override the config file with flags that were explicitly set.
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "dir":
+			options.Directories = directories
+		case "stable-for":
+			options.StableFor = *stableFor
+		case "report-name":
+			options.ReportName = *reportName
+		case "require-marker":
+			options.RequireMarker = *requireMarker
+		case "cache-dir":
+			options.CacheDir = *cacheDir
+		case "cache-full-hash":
+			options.CacheFullHash = *cacheFullHash
+		}
+	})
+
+	if len(options.Directories) == 0 {
+		slog.Error("at least one --dir is required")
+		flags.Usage()
+		return 2
+	}
+	stable, err := time.ParseDuration(options.StableFor)
+	if err != nil {
+		slog.Error("invalid stable duration", "value", options.StableFor, "error", err)
+		return 2
+	}
+	if options.ReportName == "" {
+		options.ReportName = defaultReportName
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	var qcCache *qc_cache.Cache
+	if options.CacheDir != "" {
+		qcCache = qc_cache.New(options.CacheDir, options.CacheFullHash)
+	}
+
+	err = plugin.Watch(ctx, plugin.WatchOptions{
+		Directories:   options.Directories,
+		StableFor:     stable,
+		RequireMarker: options.RequireMarker,
+		Cache:         qcCache,
+		Skip: func(dir string) bool {
+			_, err := os.Stat(filepath.Join(dir, options.ReportName))
+			return err == nil
+		},
+	}, func(dir string, out types.Output) {
+		if err := writeReport(filepath.Join(dir, options.ReportName), out); err != nil {
+			slog.Error("failed to write report", "directory", dir, "error", err)
+			return
+		}
+		slog.Info("qc finished", "directory", dir, "status", out.AnalysisInfo.Status)
+	})
+	if err != nil {
+		slog.Error("watch failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// writeReport writes the output as indented JSON to path.
+func writeReport(path string, out types.Output) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return writeJSON(file, out)
+}
+
+// writeJSON encodes data as indented JSON.
+func writeJSON(writer io.Writer, data any) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// diffCommand compares two QC results and prints the diff as JSON.
+// Each result is a JSON report file written by the run or watch subcommands, or the ID of an analysis read from the results database.
+// It returns the process exit code.
+func diffCommand(arguments []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	output := flags.String("output", "", "file to write the JSON diff to (defaults to stdout)")
+	html := flags.String("html", "", "file to write the HTML view of the diff to")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: plugin diff [options] <before> <after>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(arguments); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// The database is only opened when a result is not a file.
+	var db *bun.DB
+	var pluginName string
+	load := func(name string) (types.Report, error) {
+		if _, err := os.Stat(name); err == nil {
+			return plugin.ReadReport(name)
+		}
+		analysisId, err := uuid.Parse(name)
+		if err != nil {
+			return types.Report{}, fmt.Errorf("%s is neither a file nor an analysis ID", name)
+		}
+		if db == nil {
+			settings, err := pluginSettings.Load()
+			if err != nil {
+				return types.Report{}, err
+			}
+			options, err := settings.Database.ConnectorOptions(settings.Database.Results)
+			if err != nil {
+				return types.Report{}, err
+			}
+			db = bun.NewDB(sql.OpenDB(pgdriver.NewConnector(options...)), pgdialect.New())
+			pluginName = settings.Plugin.Name
+		}
+		return plugin.LoadReport(ctx, db, pluginName, analysisId)
+	}
+	defer func() {
+		if db != nil {
+			db.Close()
+		}
+	}()
+
+	before, err := load(flags.Arg(0))
+	if err != nil {
+		slog.Error("failed to read the before result", "error", err)
+		return 1
+	}
+	after, err := load(flags.Arg(1))
+	if err != nil {
+		slog.Error("failed to read the after result", "error", err)
+		return 1
+	}
+	diff := qc_diff.Compare(flags.Arg(0), before, flags.Arg(1), after)
+
+	if *html != "" {
+		file, err := os.Create(*html)
+		if err != nil {
+			slog.Error("failed to create html file", "error", err)
+			return 1
+		}
+		defer file.Close()
+		if err := qc_diff.WriteHTML(file, diff); err != nil {
+			slog.Error("failed to write html diff", "error", err)
+			return 1
+		}
+	}
+
+	var writer io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			slog.Error("failed to create output file", "error", err)
+			return 1
+		}
+		defer file.Close()
+		writer = file
+	}
+	if err := writeJSON(writer, diff); err != nil {
+		slog.Error("failed to write diff", "error", err)
+		return 1
+	}
+	return 0
+}
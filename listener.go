@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	plugin_db "github.com/CodeClarityCE/utility-types/plugin_db"
+	"github.com/parithera/plugin-fastqc/src/utils/logging"
+	"github.com/parithera/plugin-fastqc/src/utils/metrics"
+	pluginSettings "github.com/parithera/plugin-fastqc/src/utils/settings"
+	"github.com/parithera/plugin-fastqc/src/utils/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// heartbeatInterval is how often the consumer loop reports that it is alive and refreshes the queue metrics.
+const heartbeatInterval = 10 * time.Second
+
+var (
+	// amqpConnection is the connection used by the consumer, it is checked by the readiness probe.
+	amqpConnection atomic.Pointer[amqp.Connection]
+	// heartbeat holds the last time, in Unix seconds, the consumer loop was responsive.
+	heartbeat atomic.Int64
+)
+
+// handler processes a message received on the queue.
+// It returns an error wrapping context.Canceled when the analysis was interrupted and must be retried.
+type handler func(ctx context.Context, args any, config plugin_db.Plugin, message []byte) error
+
+// listen consumes messages from the queue until ctx is cancelled.
+//
+// Messages are acknowledged once processed. When ctx is cancelled, the consumer stops receiving new messages
+// and waits up to gracePeriod for the in-flight analyses. Analyses still running after the grace period are
+// cancelled and their messages are requeued so another replica can pick them up.
+func listen(ctx context.Context, amqpSettings pluginSettings.AMQP, queue string, callback handler, args any, config plugin_db.Plugin, gracePeriod time.Duration) error {
+	conn, err := amqpSettings.Dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	defer conn.Close()
+	amqpConnection.Store(conn)
+	defer amqpConnection.Store(nil)
+
+	// Open channel
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open a channel: %w", err)
+	}
+	defer ch.Close()
+
+	// Only fetch one message at a time so that pending messages stay available to other replicas.
+	err = ch.Qos(1, 0, false)
+	if err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	q, err := ch.QueueDeclare(
+		queue, // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare a queue: %w", err)
+	}
+
+	consumer := config.Name + "-" + fmt.Sprint(os.Getpid())
+	msgs, err := ch.Consume(
+		q.Name,   // queue
+		consumer, // consumer
+		false,    // auto-ack
+		false,    // exclusive
+		false,    // no-local
+		false,    // no-wait
+		nil,      // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register a consumer: %w", err)
+	}
+
+	// Analyses run with their own context so that they survive the shutdown signal until the grace period ends.
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+	var inFlight sync.WaitGroup
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	heartbeat.Store(time.Now().Unix())
+
+	logger := slog.Default().With("plugin", config.Name, "queue", queue)
+	logger.Info("waiting for messages")
+
+	var listenErr error
+consume:
+	for {
+		select {
+		case <-ctx.Done():
+			break consume
+		case <-ticker.C:
+			heartbeat.Store(time.Now().Unix())
+			if queueState, err := ch.QueueDeclarePassive(q.Name, true, false, false, false, nil); err == nil {
+				metrics.QueueMessages.Set(float64(queueState.Messages))
+			}
+		case d, ok := <-msgs:
+			if !ok {
+				listenErr = fmt.Errorf("consumer channel closed")
+				break consume
+			}
+			heartbeat.Store(time.Now().Unix())
+			if !d.Timestamp.IsZero() {
+				metrics.QueueLag.Observe(time.Since(d.Timestamp).Seconds())
+			}
+			inFlight.Add(1)
+			metrics.ActiveWorkers.Inc()
+			go func(d amqp.Delivery) {
+				defer inFlight.Done()
+				defer metrics.ActiveWorkers.Dec()
+				// Continue the trace started by the publisher, if any.
+				msgCtx := otel.GetTextMapPropagator().Extract(jobCtx, amqpHeaders(d.Headers))
+				msgCtx, span := tracing.Tracer.Start(msgCtx, "receive "+q.Name, trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+					attribute.String("messaging.system", "rabbitmq"),
+					attribute.String("messaging.destination.name", q.Name),
+				))
+				defer span.End()
+
+				err := callback(msgCtx, args, config, d.Body)
+				if err != nil {
+					tracing.RecordError(span, err)
+				}
+				// Cancellation is only reported before the result is stored, see callback, so the retry cannot duplicate it.
+				if errors.Is(err, context.Canceled) {
+					logger.Warn("analysis interrupted, requeuing message")
+					if err := d.Nack(false, true); err != nil {
+						logger.Error("failed to requeue message", "error", err)
+					}
+					return
+				}
+				// Other failures are already recorded on the analysis, retrying them would fail again.
+				if err := d.Ack(false); err != nil {
+					logger.Error("failed to acknowledge message", "error", err)
+				}
+			}(d)
+		}
+	}
+
+	// Stop receiving new messages, prefetched ones are requeued by the broker.
+	if err := ch.Cancel(consumer, false); err != nil {
+		logger.Error("failed to cancel consumer", "error", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(done)
+	}()
+
+	logger.Info("stopping, waiting for in-flight analyses", "grace_period", gracePeriod.String())
+	select {
+	case <-done:
+	case <-time.After(gracePeriod):
+		logger.Warn("grace period elapsed, cancelling in-flight analyses")
+		cancelJobs()
+		<-done
+	}
+
+	return listenErr
+}
+
+// send publishes data on the queue, propagating the trace context of ctx in the message headers.
+func send(ctx context.Context, amqpSettings pluginSettings.AMQP, queue string, data []byte) error {
+	ctx, span := tracing.Tracer.Start(ctx, "send "+queue, trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(
+		attribute.String("messaging.system", "rabbitmq"),
+		attribute.String("messaging.destination.name", queue),
+	))
+	defer span.End()
+
+	logger := logging.FromContext(ctx).With("queue", queue)
+	// The body is only logged at debug, the analysis is identified by the attributes of the logger in ctx.
+	logger.Debug("sending message", "body", string(data))
+	conn, err := amqpSettings.Dial()
+	if err != nil {
+		tracing.RecordError(span, err)
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		tracing.RecordError(span, err)
+		return fmt.Errorf("failed to open a channel: %w", err)
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclare(
+		queue, // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return fmt.Errorf("failed to declare a queue: %w", err)
+	}
+
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, amqpHeaders(headers))
+
+	publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err = ch.PublishWithContext(publishCtx,
+		"",     // exchange
+		q.Name, // routing key
+		false,  // mandatory
+		false,  // immediate
+		amqp.Publishing{
+			Headers:     headers,
+			ContentType: "text/javascript",
+			Timestamp:   time.Now(),
+			Body:        data,
+		})
+	if err != nil {
+		tracing.RecordError(span, err)
+		return fmt.Errorf("failed to publish a message: %w", err)
+	}
+	logger.Info("message sent")
+	return nil
+}
+
+// amqpHeaders adapts the headers of an AMQP message to carry the trace context.
+type amqpHeaders amqp.Table
+
+func (h amqpHeaders) Get(key string) string {
+	switch value := h[key].(type) {
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	}
+	return ""
+}
+
+func (h amqpHeaders) Set(key string, value string) {
+	h[key] = value
+}
+
+func (h amqpHeaders) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -1,19 +1,33 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
 	"time"
 
-	amqp_helper "github.com/CodeClarityCE/utility-amqp-helper"
-	dbhelper "github.com/CodeClarityCE/utility-dbhelper/helper"
 	types_amqp "github.com/CodeClarityCE/utility-types/amqp"
 	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	exceptionManager "github.com/CodeClarityCE/utility-types/exceptions"
 	plugin_db "github.com/CodeClarityCE/utility-types/plugin_db"
 	plugin "github.com/parithera/plugin-fastqc/src"
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/audit"
+	"github.com/parithera/plugin-fastqc/src/utils/logging"
+	"github.com/parithera/plugin-fastqc/src/utils/qc_cache"
+	"github.com/parithera/plugin-fastqc/src/utils/qc_store"
+	pluginSettings "github.com/parithera/plugin-fastqc/src/utils/settings"
+	"github.com/parithera/plugin-fastqc/src/utils/tracing"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
@@ -21,73 +35,227 @@ import (
 
 // Arguments struct to pass dependencies to the callback function.
 type Arguments struct {
-	codeclarity *bun.DB // Database connection.
+	codeclarity *bun.DB                 // Database connection.
+	settings    pluginSettings.Settings // Plugin configuration.
+	cache       *qc_cache.Cache         // Per-file QC results, nil when caching is disabled.
 }
 
 // main is the entry point of the program.
-// It reads the configuration, initializes the necessary databases and graph,
-// and starts listening on the queue.
+// Without a subcommand, or with "listen", it reads the configuration, initializes the necessary databases and graph,
+// and starts listening on the queue until SIGTERM or SIGINT is received.
+// The "run" and "watch" subcommands analyze local folders instead, see runCommand and watchCommand.
+// The "diff" subcommand compares two results, see diffCommand.
 func main() {
-	config, err := readConfig()
+	logging.Init()
+
+	shutdownTracing, err := tracing.Init(context.Background())
 	if err != nil {
-		log.Printf("%v", err) // Log the error if configuration reading fails.
+		slog.Error("failed to initialize tracing", "error", err)
 		return
 	}
+	defer shutdownTracing(context.Background())
 
-	host := os.Getenv("PG_DB_HOST")
-	if host == "" {
-		log.Printf("PG_DB_HOST is not set") // Log if the environment variable is not set.
-		return
+	// exit flushes the pending spans before terminating, deferred calls do not run on os.Exit.
+	exit := func(code int) {
+		shutdownTracing(context.Background())
+		os.Exit(code)
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "listen":
+		case "run":
+			exit(runCommand(os.Args[2:]))
+		case "watch":
+			exit(watchCommand(os.Args[2:]))
+		case "diff":
+			exit(diffCommand(os.Args[2:]))
+		default:
+			fmt.Fprint(os.Stderr, usage)
+			exit(2)
+		}
 	}
-	port := os.Getenv("PG_DB_PORT")
-	if port == "" {
-		log.Printf("PG_DB_PORT is not set") // Log if the environment variable is not set.
+
+	// Load and validate the configuration, reporting every problem at once.
+	settings, err := pluginSettings.Load()
+	err = errors.Join(err, settings.Validate())
+	if err != nil {
+		slog.Error("invalid configuration", "error", err) // Log the error if configuration reading fails.
 		return
 	}
-	user := os.Getenv("PG_DB_USER")
-	if user == "" {
-		log.Printf("PG_DB_USER is not set") // Log if the environment variable is not set.
+	if err := logging.Level.UnmarshalText([]byte(settings.LogLevel)); err != nil {
+		slog.Error("invalid log level", "error", err)
 		return
 	}
-	password := os.Getenv("PG_DB_PASSWORD")
-	if password == "" {
-		log.Printf("PG_DB_PASSWORD is not set") // Log if the environment variable is not set.
+	slog.Debug("configuration loaded", "settings", settings.Dump())
+	closeAudit, err := audit.Init(settings.AuditLog)
+	if err != nil {
+		slog.Error("failed to open the audit log", "error", err)
 		return
 	}
+	defer closeAudit()
+	config := settings.Plugin
 
-	// Construct the database connection string.
-	dsn := "postgres://" + user + ":" + password + "@" + host + ":" + port + "/" + dbhelper.Config.Database.Results + "?sslmode=disable"
+	err = register(settings)
+	if err != nil {
+		slog.Error("failed to register plugin", "error", err)
+		return
+	}
 
 	// Open a database connection.
-	sqldb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn), pgdriver.WithTimeout(50*time.Second)))
+	options, err := settings.Database.ConnectorOptions(settings.Database.Results)
+	if err != nil {
+		slog.Error("invalid database settings", "error", err)
+		return
+	}
+	sqldb := sql.OpenDB(pgdriver.NewConnector(append(options, pgdriver.WithTimeout(50*time.Second))...))
 
 	// Create a Bun database connection.
 	db_codeclarity := bun.NewDB(sqldb, pgdialect.New())
 	defer db_codeclarity.Close() // Ensure the database connection is closed when the function exits.
 
+	// Create or upgrade the QC metrics tables.
+	if err := qc_store.Migrate(context.Background(), db_codeclarity); err != nil {
+		slog.Error("failed to migrate the qc tables", "error", err)
+		return
+	}
+
 	// Create an Arguments struct to pass to the callback function.
 	args := Arguments{
 		codeclarity: db_codeclarity,
+		settings:    settings,
+	}
+	if settings.CachePath != "" {
+		args.cache = qc_cache.New(settings.CachePath, settings.CacheFullHash)
 	}
 
+	// Stop consuming when the container is asked to stop, in-flight analyses are drained by listen.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// Expose the health probes and metrics.
+	server := newHTTPServer(settings.HTTPAddr, db_codeclarity, settings.AdminToken)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("http server failed", "error", err)
+		}
+	}()
+	defer server.Shutdown(context.Background())
+
 	// Start listening on the queue.
-	amqp_helper.Listen("dispatcher_"+config.Name, callback, args, config)
+	err = listen(ctx, settings.AMQP, "dispatcher_"+config.Name, callback, args, config, settings.ShutdownGracePeriod)
+	if err != nil {
+		slog.Error("listener stopped", "error", err)
+	}
 }
 
-// startAnalysis performs the analysis using the specified plugin.
-func startAnalysis(args Arguments, dispatcherMessage types_amqp.DispatcherPluginMessage, config plugin_db.Plugin, analysis_document codeclarity.Analysis) (map[string]any, codeclarity.AnalysisStatus, error) {
+// livenessTimeout is how long the consumer loop may stay unresponsive before the liveness probe fails.
+const livenessTimeout = 3 * heartbeatInterval
 
-	// Get analysis config from the analysis document.
-	messageData := analysis_document.Config[config.Name].(map[string]any)
+// newHTTPServer creates the server exposing the health probes and the Prometheus metrics.
+// It listens on addr, HTTP_ADDR in the settings.
+//   - /healthz reports whether the consumer loop is responsive. A busy consumer stays live.
+//   - /readyz reports whether the database and the AMQP connection are usable.
+//   - /metrics exposes the Prometheus metrics.
+//   - /loglevel returns the log level on GET and changes it on PUT (e.g. "debug").
+//     A change requires the "Authorization: Bearer <adminToken>" header, ADMIN_TOKEN in the settings,
+//     and is refused when no token is configured.
+func newHTTPServer(addr string, db *bun.DB, adminToken pluginSettings.Secret) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		last := time.Unix(heartbeat.Load(), 0)
+		if time.Since(last) > livenessTimeout {
+			http.Error(w, "consumer unresponsive since "+last.Format(time.RFC3339), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		if err := db.PingContext(ctx); err != nil {
+			http.Error(w, "database: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		conn := amqpConnection.Load()
+		if conn == nil || conn.IsClosed() {
+			http.Error(w, "amqp: not connected", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/loglevel", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			if adminToken == "" {
+				audit.Denied(r.Context(), "loglevel", "no admin token is configured", "remote_addr", r.RemoteAddr)
+				http.Error(w, "log level changes are disabled, set ADMIN_TOKEN", http.StatusForbidden)
+				return
+			}
+			expected := []byte("Bearer " + adminToken.Reveal())
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+				audit.Denied(r.Context(), "loglevel", "invalid admin token", "remote_addr", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := logging.Level.UnmarshalText(bytes.TrimSpace(body)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			slog.Info("log level changed", "level", logging.Level.Level().String())
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintln(w, logging.Level.Level().String())
+	})
 
-	// Get the download path from the environment variables.
-	path := os.Getenv("DOWNLOAD_PATH")
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
 
-	// Prepare the sample path for the plugin.
-	sample := filepath.Join(path, dispatcherMessage.OrganizationId.String(), "samples", messageData["sample"].(string))
+// startAnalysis performs the analysis using the specified plugin.
+// If ctx is cancelled while the plugin runs, no result is stored and ctx.Err() is returned.
+// Once the plugin has finished, the result is stored even if ctx is cancelled; a failure to store it is returned.
+func startAnalysis(ctx context.Context, args Arguments, dispatcherMessage types_amqp.DispatcherPluginMessage, config plugin_db.Plugin, analysis_document codeclarity.Analysis) (map[string]any, codeclarity.AnalysisStatus, error) {
 
-	// Start the plugin and get the output.
-	rOutput := plugin.Start(sample, args.codeclarity)
+	// Get analysis config from the analysis document, an invalid configuration fails the analysis.
+	var rOutput types.Output
+	options, configErrors := plugin.ParseOptions(config.Config, analysis_document.Config[config.Name])
+	if len(configErrors) > 0 {
+		logging.FromContext(ctx).Warn("invalid analysis configuration", "errors", len(configErrors))
+		rOutput = plugin.FailedOutput(configErrors)
+	} else {
+		// Resolve the sample path inside the folder of the organization.
+		sample, sampleErr := plugin.ResolveSample(ctx, args.settings.DownloadPath, dispatcherMessage.OrganizationId, analysis_document, options.Sample)
+		if sampleErr != nil {
+			rOutput = plugin.FailedOutput([]exceptionManager.Error{*sampleErr})
+		} else {
+			// Start the plugin and get the output.
+			// Each analysis writes to its own directory, see plugin.OutputDir.
+			outputDir := plugin.OutputDir(args.settings.OutputPath, sample, dispatcherMessage.AnalysisId.String())
+			rOutput = plugin.Start(ctx, sample, outputDir, args.codeclarity, args.cache)
+			if ctx.Err() != nil {
+				return nil, "", ctx.Err()
+			}
+			// Drift detection is advisory, the analysis is stored without it when the history cannot be read.
+			// It runs before the insert so that the history does not include this analysis.
+			if err := plugin.DetectDrift(ctx, plugin.StoredHistory(args.codeclarity), analysis_document, &rOutput); err != nil {
+				logging.FromContext(ctx).Warn("drift detection failed", "error", err)
+			}
+		}
+	}
 
 	// Create a result object to store the plugin output.
 	result := codeclarity.Result{
@@ -96,15 +264,35 @@ func startAnalysis(args Arguments, dispatcherMessage types_amqp.DispatcherPlugin
 		Plugin:     config.Name,
 	}
 
-	// Insert the result into the database.
-	_, err := args.codeclarity.NewInsert().Model(&result).Exec(context.Background())
+	// Insert the result into the database, with the QC metrics of a successful analysis in the same transaction.
+	// The analysis is complete at this point, a shutdown must not interrupt the insert and lose its result.
+	insertCtx, insertSpan := tracing.Tracer.Start(context.WithoutCancel(ctx), "insert result")
+	err := args.codeclarity.RunInTx(insertCtx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(&result).Exec(ctx); err != nil {
+			return err
+		}
+		report, ok := rOutput.Result.Data.(types.Report)
+		if !ok {
+			return nil
+		}
+		run := qc_store.NewRun(report)
+		run.AnalysisId = dispatcherMessage.AnalysisId
+		run.ResultId = result.Id
+		run.OrganizationId = dispatcherMessage.OrganizationId
+		run.ProjectId = analysis_document.ProjectId
+		run.Sample = options.Sample
+		return qc_store.Insert(ctx, tx, run)
+	})
 	if err != nil {
-		panic(err) // Handle the error appropriately in a production environment.
+		tracing.RecordError(insertSpan, err)
+		insertSpan.End()
+		return nil, "", fmt.Errorf("failed to store the result: %w", err)
 	}
+	insertSpan.End()
 
 	// Prepare the result to store in step.
-	// In this case we only store the sbomKey.
-	// The other plugins will use this key to get the sbom.
+	// In this case we only store the key of the result.
+	// The other plugins will use this key to get the report and its artifacts.
 	res := make(map[string]any)
 	res["rKey"] = result.Id
 
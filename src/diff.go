@@ -0,0 +1,61 @@
+package fastqc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// DecodeReport extracts the report of a types.Output encoded as JSON.
+// It fails when the analysis did not succeed, since a failed analysis has no report.
+func DecodeReport(data []byte) (types.Report, error) {
+	var output struct {
+		Result struct {
+			Data *types.Report `json:"data"`
+		} `json:"result"`
+		AnalysisInfo types.AnalysisInfo `json:"analysis_info"`
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return types.Report{}, err
+	}
+	if output.AnalysisInfo.Status != codeclarity.SUCCESS || output.Result.Data == nil {
+		return types.Report{}, fmt.Errorf("the analysis has no report, status %q", output.AnalysisInfo.Status)
+	}
+	return *output.Result.Data, nil
+}
+
+// ReadReport reads the report of an output file written by the run or watch subcommands.
+func ReadReport(path string) (types.Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return types.Report{}, err
+	}
+	report, err := DecodeReport(data)
+	if err != nil {
+		return types.Report{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return report, nil
+}
+
+// LoadReport reads the report stored in the results database for the analysis analysisId by the plugin named pluginName.
+func LoadReport(ctx context.Context, db bun.IDB, pluginName string, analysisId uuid.UUID) (types.Report, error) {
+	var data []byte
+	err := db.NewSelect().Model((*codeclarity.Result)(nil)).Column("result").
+		Where(`"analysisId" = ?`, analysisId).Where("plugin = ?", pluginName).
+		Limit(1).Scan(ctx, &data)
+	if err != nil {
+		return types.Report{}, fmt.Errorf("analysis %s: %w", analysisId, err)
+	}
+	report, err := DecodeReport(data)
+	if err != nil {
+		return types.Report{}, fmt.Errorf("analysis %s: %w", analysisId, err)
+	}
+	return report, nil
+}
@@ -0,0 +1,61 @@
+package fastqc
+
+import (
+	"context"
+	"fmt"
+
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/drift"
+	"github.com/parithera/plugin-fastqc/src/utils/error_classifier"
+	"github.com/parithera/plugin-fastqc/src/utils/qc_store"
+	"github.com/parithera/plugin-fastqc/src/utils/tracing"
+)
+
+// History reads the QC files of the analyses preceding analysisId, up to limit runs, see qc_store.History.
+type History func(ctx context.Context, organizationId uuid.UUID, projectId *uuid.UUID, analysisId uuid.UUID, limit int) ([]types.QCFile, error)
+
+// StoredHistory reads the history from the QC tables of db.
+func StoredHistory(db bun.IDB) History {
+	return func(ctx context.Context, organizationId uuid.UUID, projectId *uuid.UUID, analysisId uuid.UUID, limit int) ([]types.QCFile, error) {
+		return qc_store.History(ctx, db, organizationId, projectId, analysisId, limit)
+	}
+}
+
+// DetectDrift compares the metrics of a successful output with the earlier analyses of the project of analysis,
+// or of its organization when it has no project, see drift.Detect.
+//
+// The outliers are listed in the report and each one is reported as a QC_DRIFT error. The analysis still succeeds:
+// a drift is a warning for the user, not a failure of the plugin.
+func DetectDrift(ctx context.Context, history History, analysis codeclarity.Analysis, output *types.Output) error {
+	report, ok := output.Result.Data.(types.Report)
+	if !ok {
+		return nil
+	}
+	ctx, span := tracing.Tracer.Start(ctx, "drift detection")
+	defer span.End()
+
+	baseline, err := history(ctx, analysis.OrganizationId, analysis.ProjectId, analysis.Id, drift.WINDOW)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	scope := "organization"
+	if analysis.ProjectId != nil {
+		scope = "project"
+	}
+	for _, file := range qc_store.NewRun(report).Files {
+		for _, outlier := range drift.Detect(baseline, file) {
+			report.Drift = append(report.Drift, outlier)
+			output.AnalysisInfo.Errors = append(output.AnalysisInfo.Errors, error_classifier.NewError(types.QC_DRIFT,
+				fmt.Sprintf("%s: %s=%g, %s center %g, score %.2f over %d files", outlier.Source, outlier.Metric, outlier.Value, outlier.Method, outlier.Center, outlier.Score, outlier.BaselineSize),
+				fmt.Sprintf("Warning: %s of %s (%g) deviates from the previous analyses of the %s (typical value %g)", outlier.Metric, outlier.Source, outlier.Value, scope, outlier.Center)))
+		}
+	}
+	output.Result.Data = report
+	return nil
+}
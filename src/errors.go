@@ -0,0 +1,12 @@
+package fastqc
+
+import (
+	exceptionManager "github.com/CodeClarityCE/utility-types/exceptions"
+
+	"github.com/parithera/plugin-fastqc/src/utils/error_classifier"
+)
+
+// genericError builds the GENERIC_ERROR reported when a step of the analysis fails with err.
+func genericError(public string, err error) exceptionManager.Error {
+	return error_classifier.NewError(exceptionManager.GENERIC_ERROR, err.Error(), public)
+}
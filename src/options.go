@@ -0,0 +1,91 @@
+package fastqc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	exceptionManager "github.com/CodeClarityCE/utility-types/exceptions"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/error_classifier"
+)
+
+// ParseOptions validates the analysis configuration of the plugin against schema and decodes it.
+//
+// Parameters:
+//
+//	schema: The JSON Schema published in the "config" block of config.json.
+//	raw: The plugin configuration found in the analysis document, as decoded from JSON.
+//
+// Returns:
+//
+//	The decoded options, or one INVALID_CONFIGURATION error per invalid field.
+func ParseOptions(schema map[string]any, raw any) (types.Options, []exceptionManager.Error) {
+	compiled, err := compileSchema(schema)
+	if err != nil {
+		return types.Options{}, []exceptionManager.Error{genericError("The plugin configuration schema is invalid", err)}
+	}
+
+	err = compiled.Validate(raw)
+	var validationError *jsonschema.ValidationError
+	if errors.As(err, &validationError) {
+		return types.Options{}, fieldErrors(validationError)
+	}
+	if err != nil {
+		return types.Options{}, []exceptionManager.Error{invalidConfiguration("configuration", err.Error())}
+	}
+
+	// The configuration matches the schema, decoding it cannot fail on the declared fields.
+	var options types.Options
+	content, _ := json.Marshal(raw)
+	if err := json.Unmarshal(content, &options); err != nil {
+		return types.Options{}, []exceptionManager.Error{invalidConfiguration("configuration", err.Error())}
+	}
+	return options, nil
+}
+
+// FailedOutput returns the output of an analysis that could not start, e.g. because of an invalid configuration.
+func FailedOutput(errors []exceptionManager.Error) types.Output {
+	return generate_output(time.Now(), nil, codeclarity.FAILURE, errors)
+}
+
+// compileSchema compiles the JSON Schema decoded from config.json.
+func compileSchema(schema map[string]any) (*jsonschema.Schema, error) {
+	content, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	return jsonschema.CompileString("config.json", string(content))
+}
+
+// fieldErrors flattens a validation error into one error per invalid field.
+func fieldErrors(validationError *jsonschema.ValidationError) []exceptionManager.Error {
+	var errs []exceptionManager.Error
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, cause := range e.Causes {
+				walk(cause)
+			}
+			return
+		}
+		field := strings.TrimPrefix(e.InstanceLocation, "/")
+		if field == "" {
+			field = "configuration"
+		}
+		errs = append(errs, invalidConfiguration(field, e.Message))
+	}
+	walk(validationError)
+	return errs
+}
+
+// invalidConfiguration builds the error reported for an invalid field.
+func invalidConfiguration(field string, message string) exceptionManager.Error {
+	description := fmt.Sprintf("%s: %s", field, message)
+	return error_classifier.NewError(types.INVALID_CONFIGURATION, description, description)
+}
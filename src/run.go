@@ -1,47 +1,89 @@
 package fastqc
 
 import (
-	"log"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
 	exceptionManager "github.com/CodeClarityCE/utility-types/exceptions"
+	"github.com/apache/arrow-go/v18/arrow"
 	"github.com/uptrace/bun"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/artifacts"
+	"github.com/parithera/plugin-fastqc/src/utils/certificate"
+	"github.com/parithera/plugin-fastqc/src/utils/charts"
+	"github.com/parithera/plugin-fastqc/src/utils/checksum"
+	"github.com/parithera/plugin-fastqc/src/utils/columnar"
+	"github.com/parithera/plugin-fastqc/src/utils/dashboard"
+	"github.com/parithera/plugin-fastqc/src/utils/error_classifier"
+	"github.com/parithera/plugin-fastqc/src/utils/fastqc_data"
+	"github.com/parithera/plugin-fastqc/src/utils/logging"
+	"github.com/parithera/plugin-fastqc/src/utils/metrics"
+	"github.com/parithera/plugin-fastqc/src/utils/multiqc"
 	"github.com/parithera/plugin-fastqc/src/utils/output_generator"
+	"github.com/parithera/plugin-fastqc/src/utils/qc_cache"
+	"github.com/parithera/plugin-fastqc/src/utils/report_images"
+	"github.com/parithera/plugin-fastqc/src/utils/tracing"
 )
 
 // Start analyzes the source code directory and generates a FastQC report.
+// The reports are written to outputDir, see OutputDir. The per-file results are reused from qcCache when it is not nil.
 // It returns a types.Output struct containing the analysis results.
-func Start(sourceCodeDir string, codeclarityDB *bun.DB) types.Output {
-	return ExecuteScript(sourceCodeDir)
+// Cancelling ctx stops the FastQC process.
+func Start(ctx context.Context, sourceCodeDir string, outputDir string, codeclarityDB *bun.DB, qcCache *qc_cache.Cache) types.Output {
+	return ExecuteScript(ctx, sourceCodeDir, outputDir, qcCache)
+}
+
+// OutputDir returns the directory receiving the reports of the analysis identified by key.
+// It is <root>/<key>, or <sourceCodeDir>/fastqc/<key> when no root is configured,
+// so that concurrent analyses of the same sample never share a directory.
+func OutputDir(root string, sourceCodeDir string, key string) string {
+	if root == "" {
+		return filepath.Join(sourceCodeDir, "fastqc", key)
+	}
+	return filepath.Join(root, key)
 }
 
 // ExecuteScript runs FastQC on the provided source code directory and returns the output.
 // It searches for .fastq.gz files, executes FastQC, and generates an output based on the results.
-func ExecuteScript(sourceCodeDir string) types.Output {
+// The FastQC process is killed if ctx is cancelled before it completes.
+//
+// The reports are written to a temporary directory next to outputDir, which replaces outputDir once every
+// file succeeded. A failed or cancelled run therefore leaves no partial reports, and a successful one
+// replaces the reports of an earlier run. The location is recorded in the output.
+//
+// When qcCache is not nil, the files already analyzed with the same FastQC version and parameters are not analyzed again:
+// their reports are copied from the cache and flagged as cached in the output.
+func ExecuteScript(ctx context.Context, sourceCodeDir string, outputDir string, qcCache *qc_cache.Cache) types.Output {
 	// Record the start time of the analysis.
 	startTime := time.Now()
 
 	// Search for .fastq.gz files in the source code directory.
+	_, discoverSpan := tracing.Tracer.Start(ctx, "discover fastq files")
 	fastqFiles, err := filepath.Glob(filepath.Join(sourceCodeDir, "*.fastq.gz"))
+	discoverSpan.SetAttributes(attribute.Int("fastqc.files", len(fastqFiles)))
+	discoverSpan.End()
 	if err != nil {
 		// Log the error and return a failure output if file searching fails.
-		log.Fatal(err)
-		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{{
-			Private: exceptionManager.ErrorContent{
-				Description: "Error while searching for fastq files",
-				Type:        exceptionManager.GENERIC_ERROR,
-			},
-			Public: exceptionManager.ErrorContent{
-				Description: "Error while searching for fastq files",
-				Type:        exceptionManager.GENERIC_ERROR,
-			},
-		}})
+		logging.FromContext(ctx).Error("error while searching for fastq files", "error", err)
+		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error while searching for fastq files", err)})
 	}
 
 	// Check if any .fastq.gz files were found.
@@ -50,48 +92,552 @@ func ExecuteScript(sourceCodeDir string) types.Output {
 		return generate_output(startTime, "no fastq file found", codeclarity.SUCCESS, []exceptionManager.Error{})
 	}
 
-	// Create the output directory for FastQC results.
-	outputPath := filepath.Join(sourceCodeDir, "fastqc")
-	err = os.MkdirAll(outputPath, os.ModePerm)
+	// Create the temporary output directory for FastQC results, on the same file system as outputDir so it can be renamed.
+	err = os.MkdirAll(filepath.Dir(outputDir), os.ModePerm)
+	var tempPath string
+	if err == nil {
+		tempPath, err = os.MkdirTemp(filepath.Dir(outputDir), "."+filepath.Base(outputDir)+".tmp-")
+	}
 	if err != nil {
-		log.Fatal(err)
-		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{{
-			Private: exceptionManager.ErrorContent{
-				Description: "Error creating output directory",
-				Type:        exceptionManager.GENERIC_ERROR,
-			},
-			Public: exceptionManager.ErrorContent{
-				Description: "Error creating output directory",
-				Type:        exceptionManager.GENERIC_ERROR,
-			},
-		}})
+		logging.FromContext(ctx).Error("error creating output directory", "error", err)
+		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error creating output directory", err)})
+	}
+	// The temporary directory is gone once renamed, removing it only matters on failure.
+	defer os.RemoveAll(tempPath)
+	// MkdirTemp creates the directory with mode 0700, the published reports must be readable by the frontend.
+	if err := os.Chmod(tempPath, 0o755); err != nil {
+		logging.FromContext(ctx).Error("error creating output directory", "error", err)
+		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error creating output directory", err)})
+	}
+
+	// Read the checksums shipped by the sequencing provider, corrupted transfers are the most common data problem.
+	manifest, err := checksum.Find(sourceCodeDir)
+	if err != nil {
+		logging.FromContext(ctx).Error("error reading checksum manifests", "error", err)
+		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error reading the checksum manifests of the sample", err)})
+	}
+	// warnings are reported in the output of a successful analysis.
+	warnings := []exceptionManager.Error{}
+
+	// The results depend on the FastQC version, the cache is not used when it is unknown.
+	var toolVersion string
+	if qcCache != nil {
+		toolVersion, err = fastqcVersion(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Warn("cache disabled, failed to read the fastqc version", "error", err)
+			qcCache = nil
+		}
+	}
+
+	// Run FastQC on each file so that failures and timings can be attributed to a file.
+	files := make([]types.FileReport, 0, len(fastqFiles))
+	for _, fastqFile := range fastqFiles {
+		var cacheKey string
+		if qcCache != nil {
+			var cached types.FileReport
+			var hit bool
+			cached, cacheKey, hit = lookupCache(ctx, qcCache, tempPath, fastqFile, toolVersion)
+			if hit {
+				var checksumError *exceptionManager.Error
+				cached.Verification, checksumError = verifyChecksum(manifest, cached.Source, cached.MD5)
+				if checksumError != nil && checksumError.Public.Type == types.CHECKSUM_MISMATCH {
+					return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{*checksumError})
+				} else if checksumError != nil {
+					warnings = append(warnings, *checksumError)
+				}
+				files = append(files, cached)
+				continue
+			}
+		}
+
+		// The checksums are computed on the bytes streamed to FastQC, the input is only read once.
+		input, codeclarityError := runFastQC(ctx, tempPath, fastqFile)
+		if codeclarityError != nil {
+			// Return an output indicating failure with the error object.
+			return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{*codeclarityError})
+		}
+		verification, checksumError := verifyChecksum(manifest, filepath.Base(fastqFile), input.MD5)
+		if checksumError != nil && checksumError.Public.Type == types.CHECKSUM_MISMATCH {
+			logging.FromContext(ctx).Error("checksum mismatch", "file", filepath.Base(fastqFile), "md5", input.MD5)
+			return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{*checksumError})
+		} else if checksumError != nil {
+			warnings = append(warnings, *checksumError)
+		}
+
+		// Unpack the plots so that the frontend can embed a single chart.
+		modules, err := report_images.Extract(tempPath, reportStem(fastqFile))
+		if err != nil {
+			logging.FromContext(ctx).Error("error extracting plots", "file", filepath.Base(fastqFile), "error", err)
+			return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error extracting the plots of the FastQC report", err)})
+		}
+
+		// Draw our own charts from the metrics so that they can be restyled.
+		qcMetrics, qcCharts, err := renderCharts(ctx, tempPath, reportStem(fastqFile))
+		if err != nil {
+			logging.FromContext(ctx).Error("error rendering charts", "file", filepath.Base(fastqFile), "error", err)
+			return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error reading the FastQC report", err)})
+		}
+
+		report := types.FileReport{
+			Source:       filepath.Base(fastqFile),
+			Size:         input.Size,
+			MD5:          input.MD5,
+			SHA256:       input.SHA256,
+			Modules:      modules,
+			Charts:       qcCharts,
+			Metrics:      qcMetrics,
+			Verification: verification,
+		}
+		files = append(files, report)
+
+		// A failure to fill the cache only costs a FastQC run next time.
+		if cacheKey != "" {
+			stem := reportStem(fastqFile)
+			if err := qcCache.Store(cacheKey, tempPath, []string{stem + ".html", stem + ".zip", stem}, report); err != nil {
+				logging.FromContext(ctx).Warn("failed to cache the results", "file", report.Source, "error", err)
+			}
+		}
+	}
+
+	// Report the FASTQ files listed next to the manifests that were not delivered.
+	warnings = append(warnings, missingFiles(manifest, fastqFiles)...)
+
+	// Aggregate the files in a single report, nobody opens one FastQC report per file.
+	if err := writeDashboard(ctx, tempPath, filepath.Base(sourceCodeDir), files); err != nil {
+		logging.FromContext(ctx).Error("error writing dashboard", "error", err)
+		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error writing the aggregate report", err)})
+	}
+
+	// Export the metrics in the MultiQC format so that external reports can ingest them.
+	if err := writeMultiQC(ctx, tempPath, files); err != nil {
+		logging.FromContext(ctx).Error("error writing multiqc data", "error", err)
+		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error writing the MultiQC data", err)})
+	}
+
+	// Summarise the analysis in a certificate that the sequencing core can sign off.
+	if err := writeCertificate(ctx, tempPath, sourceCodeDir, outputDir, files); err != nil {
+		logging.FromContext(ctx).Error("error writing certificate", "error", err)
+		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error writing the QC certificate", err)})
+	}
+
+	// Export tidy metric tables for analytics.
+	if err := writeColumnar(ctx, tempPath, files); err != nil {
+		logging.FromContext(ctx).Error("error writing metric tables", "error", err)
+		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error writing the metric tables", err)})
 	}
 
-	// Prepare arguments for the FastQC command.
-	args := []string{"-o", outputPath, "-t", "1"}
-	args = append(args, fastqFiles...)
+	// Publish the reports, replacing those of a previous run of the same analysis.
+	if err := publishDir(tempPath, outputDir); err != nil {
+		logging.FromContext(ctx).Error("error publishing output directory", "error", err)
+		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error publishing output directory", err)})
+	}
 
-	// Run the FastQC command with the prepared arguments.
-	cmd := exec.Command("fastqc", args...)
-	outputBytes, err := cmd.CombinedOutput()
+	// Register the generated files so that the frontend and downstream plugins do not have to guess their paths.
+	registry, err := artifacts.Collect(outputDir, func(path string) string {
+		return sourceOf(path, fastqFiles)
+	})
 	if err != nil {
-		// Create an error object if the FastQC command fails.
-		codeclarityError := exceptionManager.Error{
-			Private: exceptionManager.ErrorContent{
-				Description: string(outputBytes),
-				Type:        exceptionManager.GENERIC_ERROR,
-			},
-			Public: exceptionManager.ErrorContent{
-				Description: "The FastQC script failed to execute",
-				Type:        exceptionManager.GENERIC_ERROR,
-			},
+		logging.FromContext(ctx).Error("error registering artifacts", "error", err)
+		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{genericError("Error registering the generated reports", err)})
+	}
+
+	// If every FastQC command succeeds, return an output indicating success.
+	output := generate_output(startTime, types.Report{Dashboard: DASHBOARD_FILE, Certificate: CERTIFICATE_FILE, MultiQC: multiqc.DATA_DIR, Files: files, Artifacts: registry}, codeclarity.SUCCESS, warnings)
+	output.AnalysisInfo.OutputDirectory = outputDir
+	return output
+}
+
+// verifyChecksum checks the md5 sum of the input named name against manifest and returns the verification status.
+// It returns a CHECKSUM_MISMATCH error when the sums differ, which must fail the analysis, and a CHECKSUM_MISSING
+// error when manifests exist but do not list the file. Without manifest, nothing is verified.
+func verifyChecksum(manifest checksum.Manifest, name string, md5 string) (string, *exceptionManager.Error) {
+	if len(manifest) == 0 {
+		return "", nil
+	}
+	entry, ok := manifest.Lookup(name)
+	if !ok {
+		unlisted := error_classifier.NewError(types.CHECKSUM_MISSING,
+			fmt.Sprintf("%s is not listed in the checksum manifests, md5 %s", name, md5),
+			fmt.Sprintf("%s is not listed in the checksum manifests of the sample, its integrity could not be verified", name))
+		return types.CHECKSUM_UNLISTED, &unlisted
+	}
+	if entry.MD5 != md5 {
+		mismatch := error_classifier.NewError(types.CHECKSUM_MISMATCH,
+			fmt.Sprintf("%s: md5 %s, %s expects %s", name, md5, entry.Manifest, entry.MD5),
+			fmt.Sprintf("%s does not match the checksum listed in %s, the file is probably corrupted. Transfer it again from the sequencing provider.", name, entry.Manifest))
+		return "", &mismatch
+	}
+	return types.CHECKSUM_VERIFIED, nil
+}
+
+// missingFiles returns a CHECKSUM_MISSING error for each FASTQ file listed in manifest, at the root of the sample,
+// that is not among fastqFiles.
+func missingFiles(manifest checksum.Manifest, fastqFiles []string) []exceptionManager.Error {
+	analyzed := map[string]bool{}
+	for _, fastqFile := range fastqFiles {
+		analyzed[filepath.Base(fastqFile)] = true
+	}
+	var listed []string
+	for name := range manifest {
+		if !strings.Contains(name, "/") && strings.HasSuffix(name, ".fastq.gz") && !analyzed[name] {
+			listed = append(listed, name)
+		}
+	}
+	sort.Strings(listed)
+
+	errors := make([]exceptionManager.Error, 0, len(listed))
+	for _, name := range listed {
+		errors = append(errors, error_classifier.NewError(types.CHECKSUM_MISSING,
+			fmt.Sprintf("%s is listed in %s but not found", name, manifest[name].Manifest),
+			fmt.Sprintf("%s is listed in %s but was not delivered with the sample", name, manifest[name].Manifest)))
+	}
+	return errors
+}
+
+// lookupCache returns the cached results of fastqFile, after copying their reports to outputDir.
+// It also returns the key of the file, which is empty when the file cannot be fingerprinted.
+// Lookup failures are logged and treated as misses.
+func lookupCache(ctx context.Context, qcCache *qc_cache.Cache, outputDir string, fastqFile string, toolVersion string) (types.FileReport, string, bool) {
+	_, span := tracing.Tracer.Start(ctx, "cache lookup", trace.WithAttributes(attribute.String("fastqc.file", filepath.Base(fastqFile))))
+	defer span.End()
+	logger := logging.FromContext(ctx).With("file", filepath.Base(fastqFile))
+
+	fingerprint, err := qcCache.Fingerprint(fastqFile)
+	if err != nil {
+		tracing.RecordError(span, err)
+		logger.Warn("failed to fingerprint the file", "error", err)
+		return types.FileReport{}, "", false
+	}
+	key := qc_cache.Key(fingerprint, toolVersion, FASTQC_PARAMETERS)
+	report, hit, err := qcCache.Load(key, outputDir)
+	if err != nil {
+		tracing.RecordError(span, err)
+		logger.Warn("failed to read the cached results", "key", key, "error", err)
+		hit = false
+	}
+	span.SetAttributes(attribute.Bool("fastqc.cache_hit", hit))
+	if hit {
+		metrics.CacheLookups.WithLabelValues(metrics.CACHE_HIT).Inc()
+		logger.Info("results found in cache", "key", key)
+	} else {
+		metrics.CacheLookups.WithLabelValues(metrics.CACHE_MISS).Inc()
+	}
+	return report, key, hit
+}
+
+// fastqcVersion returns the version printed by FastQC, e.g. "FastQC v0.12.1".
+func fastqcVersion(ctx context.Context) (string, error) {
+	output, err := exec.CommandContext(ctx, "fastqc", "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// renderCharts parses the metrics of the FastQC archive <outputDir>/<stem>.zip and renders the QC charts
+// to <outputDir>/<stem>/charts as SVG and PNG.
+func renderCharts(ctx context.Context, outputDir string, stem string) (types.QCMetrics, []types.Chart, error) {
+	_, parseSpan := tracing.Tracer.Start(ctx, "report parsing")
+	qcMetrics, err := fastqc_data.ReadArchive(filepath.Join(outputDir, stem+".zip"), stem)
+	if err != nil {
+		tracing.RecordError(parseSpan, err)
+	}
+	parseSpan.End()
+	if err != nil {
+		return qcMetrics, nil, err
+	}
+
+	_, renderSpan := tracing.Tracer.Start(ctx, "chart rendering")
+	defer renderSpan.End()
+	chartsDir := filepath.Join(outputDir, stem, "charts")
+	if err := os.MkdirAll(chartsDir, os.ModePerm); err != nil {
+		return qcMetrics, nil, err
+	}
+	result := []types.Chart{}
+	for _, figure := range charts.Render(qcMetrics) {
+		chart := types.Chart{
+			Id:   figure.Id,
+			Name: figure.Name,
+			SVG:  path.Join(stem, "charts", figure.Id+".svg"),
+			PNG:  path.Join(stem, "charts", figure.Id+".png"),
 		}
-		// Return an output indicating failure with the error object.
-		return generate_output(startTime, nil, codeclarity.FAILURE, []exceptionManager.Error{codeclarityError})
+		content, err := figure.Figure.PNG()
+		if err == nil {
+			err = os.WriteFile(filepath.Join(outputDir, filepath.FromSlash(chart.PNG)), content, 0o644)
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(outputDir, filepath.FromSlash(chart.SVG)), figure.Figure.SVG(), 0o644)
+		}
+		if err != nil {
+			tracing.RecordError(renderSpan, err)
+			return qcMetrics, nil, err
+		}
+		result = append(result, chart)
+	}
+	return qcMetrics, result, nil
+}
+
+// DASHBOARD_FILE is the name of the aggregate report written in the output directory.
+const DASHBOARD_FILE = "fastqc_dashboard.html"
+
+// writeDashboard writes the aggregate report of files to <outputDir>/DASHBOARD_FILE.
+func writeDashboard(ctx context.Context, outputDir string, title string, files []types.FileReport) error {
+	_, span := tracing.Tracer.Start(ctx, "dashboard")
+	defer span.End()
+
+	samples := make([]dashboard.Sample, 0, len(files))
+	for _, file := range files {
+		samples = append(samples, dashboard.Sample{
+			Name:    file.Source,
+			Metrics: file.Metrics,
+			Report:  reportStem(file.Source) + ".html",
+		})
+	}
+
+	err := writeFile(filepath.Join(outputDir, DASHBOARD_FILE), func(w io.Writer) error {
+		return dashboard.Write(w, "FastQC: "+title, samples)
+	})
+	if err != nil {
+		tracing.RecordError(span, err)
+	}
+	return err
+}
+
+// writeMultiQC writes multiqc_data.json and the general statistics tables of files to <outputDir>/multiqc_data.
+func writeMultiQC(ctx context.Context, outputDir string, files []types.FileReport) error {
+	_, span := tracing.Tracer.Start(ctx, "multiqc export")
+	defer span.End()
+
+	samples := make([]multiqc.Sample, 0, len(files))
+	for _, file := range files {
+		samples = append(samples, multiqc.Sample{Source: file.Source, Metrics: file.Metrics})
+	}
+
+	dataDir := filepath.Join(outputDir, multiqc.DATA_DIR)
+	if err := os.MkdirAll(dataDir, os.ModePerm); err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	fastqcHeader, fastqcRows := multiqc.FastQCTable(samples)
+	statsHeader, statsRows := multiqc.GeneralStatsTable(samples)
+	writers := map[string]func(io.Writer) error{
+		multiqc.JSON_FILE: func(w io.Writer) error { return multiqc.WriteJSON(w, samples) },
+		multiqc.FASTQC_TSV: func(w io.Writer) error {
+			return multiqc.WriteTSV(w, fastqcHeader, fastqcRows)
+		},
+		multiqc.GENERAL_STATS: func(w io.Writer) error {
+			return multiqc.WriteTSV(w, statsHeader, statsRows)
+		},
+	}
+	for name, write := range writers {
+		if err := writeFile(filepath.Join(dataDir, name), write); err != nil {
+			tracing.RecordError(span, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// CERTIFICATE_FILE is the name of the PDF certificate written in the output directory.
+const CERTIFICATE_FILE = "fastqc_certificate.pdf"
+
+// writeCertificate writes the PDF certificate of files to <tempPath>/CERTIFICATE_FILE.
+// The project is named after the sample folder and the analysis after the final output directory.
+func writeCertificate(ctx context.Context, tempPath string, sourceCodeDir string, outputDir string, files []types.FileReport) error {
+	_, span := tracing.Tracer.Start(ctx, "certificate")
+	defer span.End()
+
+	err := writeFile(filepath.Join(tempPath, CERTIFICATE_FILE), func(w io.Writer) error {
+		return certificate.Write(w, certificate.Certificate{
+			Project:     filepath.Base(sourceCodeDir),
+			Analysis:    filepath.Base(outputDir),
+			GeneratedAt: time.Now(),
+			Parameters:  FASTQC_PARAMETERS,
+			Files:       files,
+		})
+	})
+	if err != nil {
+		tracing.RecordError(span, err)
+	}
+	return err
+}
+
+// writeColumnar writes the per-file and per-position metric tables of files to outputDir as Parquet and Arrow IPC files.
+func writeColumnar(ctx context.Context, outputDir string, files []types.FileReport) error {
+	_, span := tracing.Tracer.Start(ctx, "columnar export")
+	defer span.End()
+
+	tables := map[string]arrow.Record{
+		columnar.FILE_METRICS:     columnar.FileMetrics(files),
+		columnar.POSITION_METRICS: columnar.PositionMetrics(files),
+	}
+	defer func() {
+		for _, record := range tables {
+			record.Release()
+		}
+	}()
+	for name, record := range tables {
+		err := writeFile(filepath.Join(outputDir, name+".parquet"), func(w io.Writer) error {
+			return columnar.WriteParquet(w, record)
+		})
+		if err == nil {
+			err = writeFile(filepath.Join(outputDir, name+".arrow"), func(w io.Writer) error {
+				return columnar.WriteArrow(w, record)
+			})
+		}
+		if err != nil {
+			tracing.RecordError(span, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile creates the file at path with the content written by write.
+func writeFile(path string, write func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// reportStem returns the prefix of the reports FastQC writes for fastqFile, e.g. sample_R1_fastqc for sample_R1.fastq.gz.
+func reportStem(fastqFile string) string {
+	return strings.TrimSuffix(filepath.Base(fastqFile), ".fastq.gz") + "_fastqc"
+}
+
+// sourceOf returns the name of the FASTQ file whose reports include path, or an empty string.
+func sourceOf(path string, fastqFiles []string) string {
+	name := strings.SplitN(path, "/", 2)[0]
+	for _, fastqFile := range fastqFiles {
+		stem := reportStem(fastqFile)
+		if name == stem || strings.HasPrefix(name, stem+".") {
+			return filepath.Base(fastqFile)
+		}
+	}
+	return ""
+}
+
+// publishDir renames tempPath to outputDir. An existing outputDir is renamed aside first and only removed once
+// tempPath took its place, so readers never see a partial directory and a failed rename leaves the old one in place.
+func publishDir(tempPath string, outputDir string) error {
+	if _, err := os.Lstat(outputDir); errors.Is(err, os.ErrNotExist) {
+		return os.Rename(tempPath, outputDir)
+	} else if err != nil {
+		return err
+	}
+
+	// Move the existing directory into a unique directory next to outputDir, on the same file system.
+	trash, err := os.MkdirTemp(filepath.Dir(outputDir), "."+filepath.Base(outputDir)+".old-")
+	if err != nil {
+		return err
+	}
+	oldPath := filepath.Join(trash, filepath.Base(outputDir))
+	if err := os.Rename(outputDir, oldPath); err != nil {
+		os.Remove(trash)
+		return err
+	}
+	if err := os.Rename(tempPath, outputDir); err != nil {
+		if restoreErr := os.Rename(oldPath, outputDir); restoreErr != nil {
+			return errors.Join(err, restoreErr)
+		}
+		os.Remove(trash)
+		return err
+	}
+	return os.RemoveAll(trash)
+}
+
+// FASTQC_PARAMETERS are the options passed to FastQC for every file, besides the output directory.
+var FASTQC_PARAMETERS = []string{"-t", "1"}
+
+// runFastQC runs FastQC on a single file and writes its reports to outputPath.
+// It returns the digests of the file on success, or the classified error when FastQC fails.
+//
+// The file is streamed to the standard input of FastQC, named stdin:<file name> so that the reports keep the name
+// of the file and gzip is detected from its extension (FastQC 0.12 or later). The digests are computed on the
+// streamed bytes so that the file is only read once.
+func runFastQC(ctx context.Context, outputPath string, fastqFile string) (checksum.Digests, *exceptionManager.Error) {
+	ctx, span := tracing.Tracer.Start(ctx, "fastqc", trace.WithAttributes(attribute.String("fastqc.file", filepath.Base(fastqFile))))
+	defer span.End()
+	ctx = logging.With(ctx, "file", filepath.Base(fastqFile))
+	logger := logging.FromContext(ctx)
+
+	// Record the amount of data handed to FastQC.
+	if info, err := os.Stat(fastqFile); err == nil {
+		metrics.BytesProcessed.Add(float64(info.Size()))
+		span.SetAttributes(attribute.Int64("fastqc.file_size", info.Size()))
+	}
+
+	input, err := os.Open(fastqFile)
+	if err != nil {
+		tracing.RecordError(span, err)
+		logger.Error("failed to open the input", "error", err)
+		inputError := genericError("Error reading the input file", err)
+		if errors.Is(err, fs.ErrPermission) {
+			inputError = error_classifier.Classify(err.Error(), err)
+		}
+		return checksum.Digests{}, &inputError
 	}
+	defer input.Close()
+	hasher := checksum.NewHasher()
+
+	// Run the FastQC command on the file.
+	// Its output is kept for the error classifier and logged line by line at debug level.
+	var output syncBuffer
+	stdout := logging.NewLineWriter(ctx, slog.LevelDebug, "fastqc stdout")
+	stderr := logging.NewLineWriter(ctx, slog.LevelDebug, "fastqc stderr")
+	arguments := append([]string{"-o", outputPath}, FASTQC_PARAMETERS...)
+	cmd := exec.CommandContext(ctx, "fastqc", append(arguments, "stdin:"+filepath.Base(fastqFile))...)
+	cmd.Stdin = io.TeeReader(input, hasher)
+	cmd.Stdout = io.MultiWriter(&output, stdout)
+	cmd.Stderr = io.MultiWriter(&output, stderr)
+	logger.Info("running fastqc")
+	err = cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+
+	exitCode := cmd.ProcessState.ExitCode()
+	metrics.ExitCodes.WithLabelValues(strconv.Itoa(exitCode)).Inc()
+	span.SetAttributes(attribute.Int("process.exit.code", exitCode))
+	if err != nil {
+		tracing.RecordError(span, err)
+		logger.Error("fastqc failed", "error", err, "exit_code", exitCode)
+		// Classify the failure so the user gets actionable guidance instead of a generic message.
+		codeclarityError := error_classifier.Classify(output.String(), err)
+		return checksum.Digests{}, &codeclarityError
+	}
+
+	// FastQC reads its input to the end, the remainder is only hashed if it stopped early.
+	if _, err := io.Copy(hasher, input); err != nil {
+		tracing.RecordError(span, err)
+		logger.Error("failed to read the input", "error", err)
+		inputError := genericError("Error reading the input file", err)
+		return checksum.Digests{}, &inputError
+	}
+	return hasher.Digests(), nil
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of a process stdout and stderr.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
 
-	// If the FastQC command succeeds, return an output indicating success.
-	return generate_output(startTime, "done", codeclarity.SUCCESS, []exceptionManager.Error{})
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
 }
 
 // generate_output creates a types.Output object based on the provided parameters.
@@ -0,0 +1,77 @@
+package fastqc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	exceptionManager "github.com/CodeClarityCE/utility-types/exceptions"
+	"github.com/google/uuid"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/audit"
+	"github.com/parithera/plugin-fastqc/src/utils/error_classifier"
+	"github.com/parithera/plugin-fastqc/src/utils/sandbox"
+	"github.com/parithera/plugin-fastqc/src/utils/tracing"
+)
+
+// ResolveSample returns the folder of the sample analyzed by analysis, <downloadPath>/<organizationId>/samples/<sample>.
+//
+// The access is denied, and recorded in the audit log, when:
+//   - the analysis does not belong to organizationId,
+//   - the sample name or a symlink would lead outside of the samples folder of the organization.
+//
+// The ownership is checked against the database through analysis, which must be the row read from the analysis table:
+// the platform has no table of samples, a sample is a folder uploaded in the samples folder of its organization and
+// only named by the configuration of the analysis. The folder sandbox is therefore the record of which organization
+// owns a sample.
+//
+// A sample folder that does not exist is reported as SAMPLE_NOT_FOUND.
+//
+// Parameters:
+//
+//	downloadPath: The folder holding the files downloaded for each organization.
+//	organizationId: The organization that requested the analysis.
+//
+// Returns:
+//
+//	The resolved folder, or the error to report in the output of the analysis.
+func ResolveSample(ctx context.Context, downloadPath string, organizationId uuid.UUID, analysis codeclarity.Analysis, sample string) (string, *exceptionManager.Error) {
+	ctx, span := tracing.Tracer.Start(ctx, "resolve sample")
+	defer span.End()
+
+	deny := func(reason string) *exceptionManager.Error {
+		audit.Denied(ctx, "sample", reason,
+			"analysis_id", analysis.Id,
+			"organization_id", organizationId,
+			"sample", sample,
+		)
+		return sampleError(types.SAMPLE_ACCESS_DENIED, reason, "The sample could not be accessed")
+	}
+
+	if analysis.OrganizationId != organizationId {
+		return "", deny("the analysis belongs to another organization")
+	}
+
+	// The samples folder of the organization is the sandbox root, a sample linking to the folder of another organization is denied.
+	path, err := sandbox.Resolve(filepath.Join(downloadPath, organizationId.String(), "samples"), sample)
+	switch {
+	case errors.Is(err, sandbox.ErrOutsideSandbox):
+		return "", deny(err.Error())
+	case errors.Is(err, os.ErrNotExist):
+		return "", sampleError(types.SAMPLE_NOT_FOUND, err.Error(), "The sample folder does not exist")
+	case err != nil:
+		tracing.RecordError(span, err)
+		return "", sampleError(exceptionManager.GENERIC_ERROR, err.Error(), "The sample could not be accessed")
+	}
+	return path, nil
+}
+
+// sampleError builds the error reported when the sample cannot be used.
+// The private description explains the cause, the public one does not disclose other organizations' data.
+func sampleError(errorType exceptionManager.ERROR_TYPE, private string, public string) *exceptionManager.Error {
+	err := error_classifier.NewError(errorType, private, public)
+	return &err
+}
@@ -0,0 +1,69 @@
+package types
+
+// Artifact is a file generated by an analysis, e.g. a FastQC report.
+type Artifact struct {
+	// Path is relative to the output directory of the analysis.
+	Path string `json:"path"`
+	// Kind is the extension of the file without the dot: html, zip, png, json...
+	Kind     string `json:"kind"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	MimeType string `json:"mime_type"`
+	// Source is the name of the analyzed file the artifact was generated from, if any.
+	Source string `json:"source,omitempty"`
+}
+
+// Report is the data of a successful analysis, stored in the result referenced by rKey.
+type Report struct {
+	// Dashboard is the path of the aggregate HTML report of all the files, relative to the output directory.
+	Dashboard string `json:"dashboard"`
+	// Certificate is the path of the PDF QC certificate, relative to the output directory.
+	Certificate string `json:"certificate"`
+	// MultiQC is the directory holding the MultiQC compatible exports, relative to the output directory.
+	MultiQC   string       `json:"multiqc"`
+	Files     []FileReport `json:"files"`
+	Artifacts []Artifact   `json:"artifacts"`
+	// Drift lists the metrics deviating from the earlier analyses of the project, see DetectDrift.
+	Drift []Outlier `json:"drift,omitempty"`
+}
+
+// FileReport holds the reports generated for an analyzed file.
+type FileReport struct {
+	// Source is the name of the analyzed file.
+	Source string `json:"source"`
+	// Size, MD5 and SHA256 identify the content of the analyzed file.
+	Size    int64     `json:"size"`
+	MD5     string    `json:"md5"`
+	SHA256  string    `json:"sha256"`
+	Modules []Module  `json:"modules"`
+	Charts  []Chart   `json:"charts"`
+	Metrics QCMetrics `json:"metrics"`
+	// Cached is set when the reports were taken from the cache instead of running FastQC, see qc_cache.
+	Cached bool `json:"cached"`
+	// Verification is the result of the check against the checksum manifests of the sample, empty when there is none.
+	Verification string `json:"verification,omitempty"`
+}
+
+// Results of the verification of an input against the checksum manifests, see FileReport.Verification.
+const (
+	CHECKSUM_VERIFIED = "verified"
+	CHECKSUM_UNLISTED = "unlisted"
+)
+
+// Module is a FastQC module with a plot that can be embedded on its own.
+type Module struct {
+	// Id is the name of the plot file, e.g. per_base_quality.
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Image is the path of the PNG plot, relative to the output directory of the analysis.
+	Image string `json:"image"`
+}
+
+// Chart is a QC chart rendered by the plugin from the parsed metrics.
+type Chart struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// SVG and PNG are the paths of the renderings, relative to the output directory of the analysis.
+	SVG string `json:"svg"`
+	PNG string `json:"png"`
+}
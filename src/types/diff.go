@@ -0,0 +1,39 @@
+package types
+
+// Diff is the comparison of two QC reports, e.g. of a library before and after it was resequenced.
+type Diff struct {
+	Before string     `json:"before"`
+	After  string     `json:"after"`
+	Files  []FileDiff `json:"files"`
+	// Removed and Added list the files only found in the before and after reports.
+	Removed []string `json:"removed,omitempty"`
+	Added   []string `json:"added,omitempty"`
+}
+
+// FileDiff is the comparison of a file of the before report with its counterpart in the after report.
+type FileDiff struct {
+	Before  string         `json:"before"`
+	After   string         `json:"after"`
+	Modules []ModuleChange `json:"modules"`
+	Metrics []MetricDelta  `json:"metrics"`
+	// OverrepresentedRemoved and OverrepresentedAdded list the sequences that disappeared and appeared.
+	OverrepresentedRemoved []Overrepresented `json:"overrepresented_removed"`
+	OverrepresentedAdded   []Overrepresented `json:"overrepresented_added"`
+}
+
+// ModuleChange is a FastQC module whose status changed. An empty status means the module did not run.
+type ModuleChange struct {
+	Module string `json:"module"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// MetricDelta is the change of a scalar metric of a file.
+type MetricDelta struct {
+	Metric string  `json:"metric"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	Delta  float64 `json:"delta"`
+	// Significant is set when the change exceeds the tolerance of the metric.
+	Significant bool `json:"significant"`
+}
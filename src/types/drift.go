@@ -0,0 +1,20 @@
+package types
+
+// Methods used to score an outlier.
+const (
+	DRIFT_MAD    = "mad"
+	DRIFT_ZSCORE = "zscore"
+)
+
+// Outlier is a metric of an analyzed file that deviates from the earlier analyses of the same project or organization.
+type Outlier struct {
+	Source string  `json:"source"`
+	Metric string  `json:"metric"`
+	Value  float64 `json:"value"`
+	// Center is the median of the baseline for the mad method and its mean for the zscore method.
+	Center float64 `json:"center"`
+	// Score is the robust z-score for the mad method and the z-score for the zscore method.
+	Score        float64 `json:"score"`
+	Method       string  `json:"method"`
+	BaselineSize int     `json:"baseline_size"`
+}
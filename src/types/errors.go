@@ -0,0 +1,34 @@
+package types
+
+import "github.com/CodeClarityCE/utility-types/exceptions"
+
+// Error types reported when the FastQC run fails for a recognised reason.
+// They complement the generic types defined in utility-types.
+const (
+	FASTQC_NOT_INSTALLED     exceptions.ERROR_TYPE = "FastQCNotInstalled"
+	FASTQC_JAVA_MISSING      exceptions.ERROR_TYPE = "FastQCJavaMissing"
+	FASTQC_OUT_OF_MEMORY     exceptions.ERROR_TYPE = "FastQCOutOfMemory"
+	FASTQC_INVALID_FORMAT    exceptions.ERROR_TYPE = "FastQCInvalidFileFormat"
+	FASTQC_TRUNCATED_GZIP    exceptions.ERROR_TYPE = "FastQCTruncatedGzip"
+	FASTQC_PERMISSION_DENIED exceptions.ERROR_TYPE = "FastQCPermissionDenied"
+	FASTQC_DISK_FULL         exceptions.ERROR_TYPE = "FastQCDiskFull"
+)
+
+// INVALID_CONFIGURATION is reported for each field of the analysis configuration that does not match the schema.
+const INVALID_CONFIGURATION exceptions.ERROR_TYPE = "InvalidConfiguration"
+
+// Error types reported when the sample of an analysis cannot be used.
+const (
+	SAMPLE_NOT_FOUND     exceptions.ERROR_TYPE = "SampleNotFound"
+	SAMPLE_ACCESS_DENIED exceptions.ERROR_TYPE = "SampleAccessDenied"
+)
+
+// QC_DRIFT is reported, without failing the analysis, for each metric that deviates from the history of the project.
+const QC_DRIFT exceptions.ERROR_TYPE = "QCDrift"
+
+// Error types reported when the inputs do not match the checksum manifests shipped with the sample.
+// A mismatch fails the analysis, a file missing from the manifests or from the sample does not.
+const (
+	CHECKSUM_MISMATCH exceptions.ERROR_TYPE = "ChecksumMismatch"
+	CHECKSUM_MISSING  exceptions.ERROR_TYPE = "ChecksumMissing"
+)
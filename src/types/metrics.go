@@ -0,0 +1,218 @@
+package types
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// QCMetrics is the content of the fastqc_data.txt file of a FastQC report.
+// The x axis of the per-position modules is kept as written by FastQC, e.g. "1" or "10-14", because positions are grouped on long reads.
+type QCMetrics struct {
+	Version            string            `json:"version"`
+	Basic              BasicStatistics   `json:"basic_statistics"`
+	Modules            []ModuleStatus    `json:"modules"`
+	PerBaseQuality     []BaseQuality     `json:"per_base_quality"`
+	PerSequenceQuality []Count           `json:"per_sequence_quality"`
+	PerSequenceGC      []Count           `json:"per_sequence_gc_content"`
+	LengthDistribution []Count           `json:"sequence_length_distribution"`
+	Duplication        DuplicationLevels `json:"duplication_levels"`
+	AdapterContent     PositionalSeries  `json:"adapter_content"`
+	Overrepresented    []Overrepresented `json:"overrepresented_sequences"`
+}
+
+// BasicStatistics is the "Basic Statistics" module.
+type BasicStatistics struct {
+	Filename                    string `json:"filename"`
+	FileType                    string `json:"file_type"`
+	Encoding                    string `json:"encoding"`
+	TotalSequences              int64  `json:"total_sequences"`
+	TotalBases                  string `json:"total_bases,omitempty"`
+	SequencesFlaggedPoorQuality int64  `json:"sequences_flagged_poor_quality"`
+	SequenceLength              string `json:"sequence_length"`
+	GCPercent                   Float  `json:"gc_percent"`
+}
+
+// Float is a value of a FastQC module. FastQC writes NaN for the positions without data, a missing value is encoded as null in JSON.
+type Float float64
+
+// Valid reports whether f holds a value, i.e. is not NaN.
+func (f Float) Valid() bool {
+	return !math.IsNaN(float64(f))
+}
+
+func (f Float) MarshalJSON() ([]byte, error) {
+	if !f.Valid() || math.IsInf(float64(f), 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(float64(f))
+}
+
+func (f *Float) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*f = Float(math.NaN())
+		return nil
+	}
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*f = Float(value)
+	return nil
+}
+
+// Module statuses reported by FastQC.
+const (
+	MODULE_PASS = "pass"
+	MODULE_WARN = "warn"
+	MODULE_FAIL = "fail"
+)
+
+// ModuleStatus is the outcome of a FastQC module.
+type ModuleStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// BaseQuality is a row of the "Per base sequence quality" module.
+type BaseQuality struct {
+	Base          string `json:"base"`
+	Mean          Float  `json:"mean"`
+	Median        Float  `json:"median"`
+	LowerQuartile Float  `json:"lower_quartile"`
+	UpperQuartile Float  `json:"upper_quartile"`
+	Percentile10  Float  `json:"percentile_10"`
+	Percentile90  Float  `json:"percentile_90"`
+}
+
+// Count is a row of a distribution module, e.g. the number of reads with a given GC content.
+type Count struct {
+	Value string `json:"value"`
+	Count Float  `json:"count"`
+}
+
+// DuplicationLevels is the "Sequence Duplication Levels" module.
+type DuplicationLevels struct {
+	TotalDeduplicatedPercentage Float              `json:"total_deduplicated_percentage"`
+	Levels                      []DuplicationLevel `json:"levels"`
+}
+
+// DuplicationLevel is a row of the "Sequence Duplication Levels" module.
+type DuplicationLevel struct {
+	Level                  string `json:"level"`
+	PercentageDeduplicated Float  `json:"percentage_deduplicated"`
+	PercentageTotal        Float  `json:"percentage_total"`
+}
+
+// PositionalSeries holds several series sharing the same positions, e.g. one per adapter.
+type PositionalSeries struct {
+	Positions []string `json:"positions"`
+	Series    []Series `json:"series"`
+}
+
+// Series is a named list of values.
+type Series struct {
+	Name   string  `json:"name"`
+	Values []Float `json:"values"`
+}
+
+// Overrepresented is a row of the "Overrepresented sequences" module.
+type Overrepresented struct {
+	Sequence   string `json:"sequence"`
+	Count      int64  `json:"count"`
+	Percentage Float  `json:"percentage"`
+	Source     string `json:"source"`
+}
+
+// Status returns the status of the module named name, or an empty string if FastQC did not run it.
+func (m QCMetrics) Status(name string) string {
+	for _, module := range m.Modules {
+		if module.Name == name {
+			return module.Status
+		}
+	}
+	return ""
+}
+
+// GeneralStats summarises the metrics of a file in a few comparable numbers.
+type GeneralStats struct {
+	TotalSequences    int64   `json:"total_sequences"`
+	PoorQuality       int64   `json:"poor_quality"`
+	SequenceLength    string  `json:"sequence_length"`
+	GCPercent         float64 `json:"gc_percent"`
+	MeanQuality       float64 `json:"mean_quality"`
+	Q30Fraction       float64 `json:"q30_fraction"`
+	PercentDuplicates float64 `json:"percent_duplicates"`
+	MaxAdapterPercent float64 `json:"max_adapter_percent"`
+	FailedModules     int     `json:"failed_modules"`
+	WarnedModules     int     `json:"warned_modules"`
+}
+
+// Summary computes the general statistics of the metrics.
+// The mean quality is the average of the per-base means, the Q30 fraction is the share of reads with a mean quality of at least 30,
+// and the adapter content is the highest of all adapters and positions. Missing values are ignored.
+func (m QCMetrics) Summary() GeneralStats {
+	stats := GeneralStats{
+		TotalSequences: m.Basic.TotalSequences,
+		PoorQuality:    m.Basic.SequencesFlaggedPoorQuality,
+		SequenceLength: m.Basic.SequenceLength,
+	}
+	if m.Basic.GCPercent.Valid() {
+		stats.GCPercent = float64(m.Basic.GCPercent)
+	}
+	positions := 0
+	for _, row := range m.PerBaseQuality {
+		if row.Mean.Valid() {
+			stats.MeanQuality += float64(row.Mean)
+			positions++
+		}
+	}
+	if positions > 0 {
+		stats.MeanQuality /= float64(positions)
+	}
+	reads, q30 := 0.0, 0.0
+	for _, row := range m.PerSequenceQuality {
+		if !row.Count.Valid() {
+			continue
+		}
+		reads += float64(row.Count)
+		if quality, err := strconv.ParseFloat(row.Value, 64); err == nil && quality >= 30 {
+			q30 += float64(row.Count)
+		}
+	}
+	if reads > 0 {
+		stats.Q30Fraction = q30 / reads
+	}
+	if len(m.Duplication.Levels) > 0 && m.Duplication.TotalDeduplicatedPercentage.Valid() {
+		stats.PercentDuplicates = 100 - float64(m.Duplication.TotalDeduplicatedPercentage)
+	}
+	for _, series := range m.AdapterContent.Series {
+		for _, value := range series.Values {
+			// NaN compares false, missing values are skipped.
+			if float64(value) > stats.MaxAdapterPercent {
+				stats.MaxAdapterPercent = float64(value)
+			}
+		}
+	}
+	for _, module := range m.Modules {
+		switch module.Status {
+		case MODULE_FAIL:
+			stats.FailedModules++
+		case MODULE_WARN:
+			stats.WarnedModules++
+		}
+	}
+	return stats
+}
+
+// OverallStatus returns the status of the file: fail if a module failed, warn if one raised a warning, pass otherwise.
+func (m QCMetrics) OverallStatus() string {
+	stats := m.Summary()
+	switch {
+	case stats.FailedModules > 0:
+		return MODULE_FAIL
+	case stats.WarnedModules > 0:
+		return MODULE_WARN
+	}
+	return MODULE_PASS
+}
@@ -0,0 +1,9 @@
+package types
+
+// Options is the configuration of the plugin for an analysis.
+// It is read from the analysis config under the plugin name and validated against the
+// JSON Schema published in the "config" block of config.json.
+type Options struct {
+	// Sample is the name of the sample folder containing the FASTQ files.
+	Sample string `json:"sample"`
+}
@@ -19,6 +19,8 @@ type AnalysisInfo struct {
 	Errors []exceptions.Error         `json:"errors"`
 	Status codeclarity.AnalysisStatus `json:"status"`
 	Extra  Extra                      `json:"extra"`
+	// OutputDirectory is where the FastQC reports were written.
+	OutputDirectory string `json:"output_directory,omitempty"`
 }
 
 type Extra struct {
@@ -0,0 +1,20 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// PluginVersion records a version of the plugin that was registered by one of its replicas.
+type PluginVersion struct {
+	bun.BaseModel `bun:"table:plugin_version,alias:plugin_version"`
+	Id            uuid.UUID      `bun:",pk,autoincrement,type:uuid,default:uuid_generate_v4()"`
+	PluginName    string         `bun:"plugin_name,notnull,unique:plugin_version_name_version"`
+	Version       string         `bun:"version,notnull,unique:plugin_version_name_version"`
+	Description   string         `bun:"description"`
+	DependsOn     []string       `bun:"depends_on"`
+	Config        map[string]any `bun:"config"`
+	RegisteredOn  time.Time      `bun:"registered_on,nullzero,notnull,default:current_timestamp"`
+}
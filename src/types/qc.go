@@ -0,0 +1,48 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// QCRun records an analysis of a sample by the plugin, with one QCFile per analyzed file.
+type QCRun struct {
+	bun.BaseModel  `bun:"table:qc_run,alias:qc_run"`
+	Id             uuid.UUID  `bun:",pk,type:uuid"`
+	AnalysisId     uuid.UUID  `bun:"analysis_id,type:uuid,notnull"`
+	ResultId       uuid.UUID  `bun:"result_id,type:uuid,notnull"`
+	OrganizationId uuid.UUID  `bun:"organization_id,type:uuid,notnull"`
+	ProjectId      *uuid.UUID `bun:"project_id,type:uuid"`
+	Sample         string     `bun:"sample,notnull"`
+	FastQCVersion  string     `bun:"fastqc_version"`
+	CreatedOn      time.Time  `bun:"created_on,nullzero,notnull,default:current_timestamp"`
+	Files          []QCFile   `bun:"rel:has-many,join:id=run_id"`
+}
+
+// QCFile holds the scalar metrics of an analyzed file.
+type QCFile struct {
+	bun.BaseModel      `bun:"table:qc_file,alias:qc_file"`
+	Id                 uuid.UUID        `bun:",pk,type:uuid"`
+	RunId              uuid.UUID        `bun:"run_id,type:uuid,notnull"`
+	Source             string           `bun:"source,notnull"`
+	Size               int64            `bun:"size"`
+	SHA256             string           `bun:"sha256"`
+	TotalReads         int64            `bun:"total_reads"`
+	GCPercent          float64          `bun:"gc_percent"`
+	MeanQuality        float64          `bun:"mean_quality"`
+	Q30Fraction        float64          `bun:"q30_fraction"`
+	DuplicationPercent float64          `bun:"duplication_percent"`
+	AdapterPercent     float64          `bun:"adapter_percent"`
+	Status             string           `bun:"status,notnull"`
+	Modules            []QCModuleStatus `bun:"rel:has-many,join:id=file_id"`
+}
+
+// QCModuleStatus is the outcome of a FastQC module for an analyzed file.
+type QCModuleStatus struct {
+	bun.BaseModel `bun:"table:qc_module_status,alias:qc_module_status"`
+	FileId        uuid.UUID `bun:"file_id,pk,type:uuid"`
+	Module        string    `bun:"module,pk"`
+	Status        string    `bun:"status,notnull"`
+}
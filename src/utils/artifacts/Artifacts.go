@@ -0,0 +1,87 @@
+package artifacts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// mimeTypes maps the kinds of the generated files to their MIME type.
+// It does not depend on the MIME database of the host so that the registry is the same everywhere.
+var mimeTypes = map[string]string{
+	"html": "text/html; charset=utf-8",
+	"zip":  "application/zip",
+	"png":  "image/png",
+	"svg":  "image/svg+xml",
+	"json": "application/json",
+	"txt":  "text/plain; charset=utf-8",
+	"tsv":  "text/tab-separated-values",
+	"pdf":  "application/pdf",
+	// There is no registered type for Parquet, this is the one used by most tools.
+	"parquet": "application/vnd.apache.parquet",
+	"arrow":   "application/vnd.apache.arrow.file",
+}
+
+// Collect describes every regular file under root, sorted by path.
+// sourceOf returns the analyzed file an artifact was generated from, given its path relative to root.
+func Collect(root string, sourceOf func(path string) string) ([]types.Artifact, error) {
+	artifacts := []types.Artifact{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		artifact, err := Describe(root, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		if sourceOf != nil {
+			artifact.Source = sourceOf(artifact.Path)
+		}
+		artifacts = append(artifacts, artifact)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Path < artifacts[j].Path })
+	return artifacts, nil
+}
+
+// Describe computes the size, checksum and type of the file at path, relative to root and slash separated.
+func Describe(root string, path string) (types.Artifact, error) {
+	file, err := os.Open(filepath.Join(root, filepath.FromSlash(path)))
+	if err != nil {
+		return types.Artifact{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return types.Artifact{}, err
+	}
+
+	kind := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	mimeType, ok := mimeTypes[kind]
+	if !ok {
+		mimeType = "application/octet-stream"
+	}
+	return types.Artifact{
+		Path:     path,
+		Kind:     kind,
+		Size:     size,
+		SHA256:   hex.EncodeToString(hash.Sum(nil)),
+		MimeType: mimeType,
+	}, nil
+}
@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/parithera/plugin-fastqc/src/utils/logging"
+)
+
+// logger writes the audit records as JSON lines. It defaults to stderr until Init is called.
+var logger = slog.New(slog.NewJSONHandler(os.Stderr, nil)).With("log", "audit")
+
+// Init sends the audit records to the file at path, appending to it, or to stderr when path is empty.
+// The returned function closes the file.
+func Init(path string) (func() error, error) {
+	if path == "" {
+		return func() error { return nil }, nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	SetOutput(file)
+	return file.Close, nil
+}
+
+// SetOutput sends the audit records to w.
+func SetOutput(w io.Writer) {
+	logger = slog.New(slog.NewJSONHandler(w, nil)).With("log", "audit")
+}
+
+// Denied records that an access to resource was refused for reason, followed by args, e.g. the analysis and organization.
+// The refusal is also logged by the logger of ctx.
+func Denied(ctx context.Context, resource string, reason string, args ...any) {
+	args = append([]any{"event", "access_denied", "resource", resource, "reason", reason}, args...)
+	logger.WarnContext(ctx, "access denied", args...)
+	logging.FromContext(ctx).Warn("access denied", "resource", resource, "reason", reason)
+}
@@ -0,0 +1,196 @@
+package certificate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/charts"
+)
+
+// Certificate is the QC summary handed to the clients of a sequencing core.
+type Certificate struct {
+	Project     string
+	Analysis    string
+	GeneratedAt time.Time
+	// Parameters are the FastQC command line options used for every file.
+	Parameters []string
+	Files      []types.FileReport
+}
+
+// Colors of the status badges.
+var badgeColors = map[string][3]int{
+	types.MODULE_PASS: {0x2c, 0xa0, 0x2c},
+	types.MODULE_WARN: {0xf0, 0xa2, 0x02},
+	types.MODULE_FAIL: {0xd6, 0x27, 0x28},
+}
+
+// Page layout, in millimetres on an A4 page.
+const (
+	margin      = 15.0
+	pageWidth   = 210.0 - 2*margin
+	lineHeight  = 6.0
+	plotHeight  = pageWidth * charts.Height / charts.Width
+	tableHeader = 7.0
+)
+
+// Write renders the certificate as a PDF document to w.
+// It only uses the core PDF fonts, which need no font file.
+func Write(w io.Writer, certificate Certificate) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin+5)
+	pdf.SetTitle("QC certificate "+certificate.Project, true)
+	pdf.SetCreator("plugin-fastqc", true)
+	pdf.AliasNbPages("")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-margin)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(0x77, 0x77, 0x77)
+		pdf.CellFormat(0, 5, tr(fmt.Sprintf("%s - page %d/{nb}", certificate.Project, pdf.PageNo())), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// Project header.
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, "Sequencing QC certificate", "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+	fastqcVersion := ""
+	if len(certificate.Files) > 0 {
+		fastqcVersion = certificate.Files[0].Metrics.Version
+	}
+	for _, field := range [][2]string{
+		{"Project", certificate.Project},
+		{"Analysis", certificate.Analysis},
+		{"Generated", certificate.GeneratedAt.UTC().Format("2006-01-02 15:04 MST")},
+		{"FastQC version", fastqcVersion},
+		{"Parameters", strings.Join(certificate.Parameters, " ")},
+		{"Files", fmt.Sprint(len(certificate.Files))},
+	} {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(35, lineHeight, tr(field[0]), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, lineHeight, tr(field[1]), "", 1, "L", false, 0, "")
+	}
+
+	// Per-sample summary.
+	section(pdf, "Summary")
+	columns := []struct {
+		title string
+		width float64
+		align string
+	}{
+		{"File", 62, "L"},
+		{"Reads", 26, "R"},
+		{"% GC", 16, "R"},
+		{"Mean Q", 18, "R"},
+		{"% Dups", 18, "R"},
+		{"% Adapter", 18, "R"},
+		{"Status", 22, "C"},
+	}
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(0xf0, 0xf0, 0xf0)
+	for _, column := range columns {
+		pdf.CellFormat(column.width, tableHeader, column.title, "1", 0, column.align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 9)
+	for _, file := range certificate.Files {
+		stats := file.Metrics.Summary()
+		values := []string{
+			fit(pdf, tr(file.Source), columns[0].width-2),
+			fmt.Sprint(stats.TotalSequences),
+			fmt.Sprintf("%.0f", stats.GCPercent),
+			fmt.Sprintf("%.1f", stats.MeanQuality),
+			fmt.Sprintf("%.1f", stats.PercentDuplicates),
+			fmt.Sprintf("%.2f", stats.MaxAdapterPercent),
+		}
+		for i, value := range values {
+			pdf.CellFormat(columns[i].width, lineHeight, value, "1", 0, columns[i].align, false, 0, "")
+		}
+		badge(pdf, file.Metrics.OverallStatus(), columns[6].width)
+		pdf.Ln(-1)
+	}
+
+	// Input checksums.
+	section(pdf, "Input files")
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.CellFormat(62, tableHeader, "File", "1", 0, "L", true, 0, "")
+	pdf.CellFormat(24, tableHeader, "Size (bytes)", "1", 0, "R", true, 0, "")
+	pdf.CellFormat(94, tableHeader, "SHA-256", "1", 1, "L", true, 0, "")
+	for _, file := range certificate.Files {
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(62, lineHeight, fit(pdf, tr(file.Source), 60), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(24, lineHeight, fmt.Sprint(file.Size), "1", 0, "R", false, 0, "")
+		pdf.SetFont("Courier", "", 7)
+		pdf.CellFormat(94, lineHeight, file.SHA256, "1", 1, "L", false, 0, "")
+	}
+
+	// Key plots, overlaid for all the files.
+	samples := make([]charts.Sample, 0, len(certificate.Files))
+	for _, file := range certificate.Files {
+		samples = append(samples, charts.Sample{Name: file.Source, Metrics: file.Metrics})
+	}
+	if len(samples) > 0 {
+		pdf.AddPage()
+		section(pdf, "Key plots")
+		for i, figure := range []charts.Figure{charts.QualityOverlay(samples), charts.GCOverlay(samples)} {
+			content, err := figure.PNG()
+			if err != nil {
+				return err
+			}
+			name := fmt.Sprintf("plot-%d", i)
+			pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(content))
+			pdf.ImageOptions(name, margin, pdf.GetY(), pageWidth, plotHeight, true, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+			pdf.Ln(4)
+		}
+	}
+
+	// Sign-off.
+	section(pdf, "Sign-off")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, label := range []string{"Reviewed by", "Date", "Signature"} {
+		pdf.CellFormat(35, 10, label, "", 0, "L", false, 0, "")
+		pdf.CellFormat(80, 10, "", "B", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+// section starts a titled section of the document.
+func section(pdf *fpdf.Fpdf, title string) {
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.CellFormat(0, 8, title, "", 1, "L", false, 0, "")
+}
+
+// badge draws a coloured status cell.
+func badge(pdf *fpdf.Fpdf, status string, width float64) {
+	color := badgeColors[status]
+	pdf.SetFillColor(color[0], color[1], color[2])
+	pdf.SetTextColor(0xff, 0xff, 0xff)
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.CellFormat(width, lineHeight, strings.ToUpper(status), "1", 0, "C", true, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.SetFillColor(0xf0, 0xf0, 0xf0)
+}
+
+// fit shortens text with an ellipsis until it fits in width.
+func fit(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
@@ -0,0 +1,286 @@
+package charts
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// Size of the rendered charts in pixels.
+const (
+	Width  = 800
+	Height = 480
+)
+
+// Figure describes a chart over categorical x values, e.g. the base positions.
+// It is rendered to SVG or PNG with the same layout.
+type Figure struct {
+	Title      string
+	XLabel     string
+	YLabel     string
+	Categories []string
+	YMin       float64
+	YMax       float64
+	// Bands are drawn behind the data, e.g. the quality zones.
+	Bands []Band
+	// Boxes holds a box plot per category, when set.
+	Boxes []Box
+	// Bars holds a bar height per category, when set.
+	Bars  []float64
+	Lines []Line
+}
+
+// Band is a horizontal area of the plot between two y values.
+type Band struct {
+	From  float64
+	To    float64
+	Color color.RGBA
+}
+
+// Box is a box plot: whiskers from Low to High, a box from Q1 to Q3, the median and the mean.
+type Box struct {
+	Low    float64
+	Q1     float64
+	Median float64
+	Q3     float64
+	High   float64
+}
+
+// valid reports whether every value of the box is set, a box holding NaN is not drawn.
+func (b Box) valid() bool {
+	for _, value := range []float64{b.Low, b.Q1, b.Median, b.Q3, b.High} {
+		if math.IsNaN(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Line is a series with a value per category. NaN values leave a gap.
+type Line struct {
+	Name   string
+	Values []float64
+	Color  color.RGBA
+	Dashed bool
+}
+
+// Named is a figure with the identifier used for its file names.
+type Named struct {
+	Id     string
+	Name   string
+	Figure Figure
+}
+
+// Colors shared by the charts.
+var (
+	red    = color.RGBA{R: 0xd6, G: 0x27, B: 0x28, A: 0xff}
+	blue   = color.RGBA{R: 0x1f, G: 0x77, B: 0xb4, A: 0xff}
+	yellow = color.RGBA{R: 0xff, G: 0xd7, B: 0x00, A: 0xff}
+	black  = color.RGBA{A: 0xff}
+	grey   = color.RGBA{R: 0xdd, G: 0xdd, B: 0xdd, A: 0xff}
+	white  = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+	badZone  = color.RGBA{R: 0xf4, G: 0xc7, B: 0xc3, A: 0xff}
+	poorZone = color.RGBA{R: 0xfc, G: 0xe8, B: 0xb2, A: 0xff}
+	goodZone = color.RGBA{R: 0xb7, G: 0xe1, B: 0xcd, A: 0xff}
+	palette  = []color.RGBA{red, blue, {R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff}, {R: 0x94, G: 0x67, B: 0xbd, A: 0xff}, {R: 0xff, G: 0x7f, B: 0x0e, A: 0xff}, {R: 0x8c, G: 0x56, B: 0x4b, A: 0xff}, {R: 0xe3, G: 0x77, B: 0xc2, A: 0xff}}
+)
+
+// Render returns the standard QC charts for which metrics has data.
+func Render(metrics types.QCMetrics) []Named {
+	var figures []Named
+	if len(metrics.PerBaseQuality) > 0 {
+		figures = append(figures, Named{"per_base_quality", "Per base sequence quality", PerBaseQuality(metrics.PerBaseQuality)})
+	}
+	if len(metrics.PerSequenceGC) > 0 {
+		figures = append(figures, Named{"per_sequence_gc_content", "Per sequence GC content", GCContent(metrics.PerSequenceGC)})
+	}
+	if len(metrics.LengthDistribution) > 0 {
+		figures = append(figures, Named{"sequence_length_distribution", "Sequence length distribution", LengthDistribution(metrics.LengthDistribution)})
+	}
+	if len(metrics.Duplication.Levels) > 0 {
+		figures = append(figures, Named{"duplication_levels", "Sequence duplication levels", Duplication(metrics.Duplication)})
+	}
+	if len(metrics.AdapterContent.Positions) > 0 {
+		figures = append(figures, Named{"adapter_content", "Adapter content", AdapterContent(metrics.AdapterContent)})
+	}
+	return figures
+}
+
+// PerBaseQuality draws the quality box plots per base position over the good, poor and bad quality zones.
+func PerBaseQuality(rows []types.BaseQuality) Figure {
+	figure := Figure{
+		Title:  "Quality scores across all bases",
+		XLabel: "Position in read (bp)",
+		YLabel: "Phred score",
+		YMax:   40,
+	}
+	mean := Line{Name: "Mean", Color: blue}
+	for _, row := range rows {
+		figure.Categories = append(figure.Categories, row.Base)
+		figure.Boxes = append(figure.Boxes, Box{
+			Low:    float64(row.Percentile10),
+			Q1:     float64(row.LowerQuartile),
+			Median: float64(row.Median),
+			Q3:     float64(row.UpperQuartile),
+			High:   float64(row.Percentile90),
+		})
+		mean.Values = append(mean.Values, float64(row.Mean))
+		// Positions without data are NaN, they must not stretch the axis.
+		if row.Percentile90.Valid() {
+			figure.YMax = math.Max(figure.YMax, math.Ceil(float64(row.Percentile90)))
+		}
+	}
+	figure.Lines = []Line{mean}
+	figure.Bands = []Band{
+		{From: 0, To: 20, Color: badZone},
+		{From: 20, To: 28, Color: poorZone},
+		{From: 28, To: figure.YMax, Color: goodZone},
+	}
+	return figure
+}
+
+// GCContent draws the GC distribution of the reads against a normal distribution fitted on it.
+// Like FastQC, the theoretical distribution is centred on the mode of the observed one.
+func GCContent(rows []types.Count) Figure {
+	figure := Figure{
+		Title:  "GC distribution over all sequences",
+		XLabel: "Mean GC content (%)",
+		YLabel: "Reads",
+	}
+	observed := Line{Name: "GC count per read", Color: red}
+	for _, row := range rows {
+		figure.Categories = append(figure.Categories, row.Value)
+		observed.Values = append(observed.Values, float64(row.Count))
+	}
+	theoretical := Line{Name: "Theoretical distribution", Color: blue, Dashed: true, Values: theoreticalGC(observed.Values)}
+	figure.Lines = []Line{observed, theoretical}
+	figure.YMax = niceCeil(math.Max(maxValue(observed.Values), maxValue(theoretical.Values)))
+	return figure
+}
+
+// theoreticalGC fits a normal distribution on counts, centred on its mode, with the same total.
+func theoreticalGC(counts []float64) []float64 {
+	total, mode := 0.0, 0
+	for i, count := range counts {
+		if math.IsNaN(count) {
+			continue
+		}
+		total += count
+		if count > counts[mode] || math.IsNaN(counts[mode]) {
+			mode = i
+		}
+	}
+	theoretical := make([]float64, len(counts))
+	if total <= 1 {
+		return theoretical
+	}
+	variance := 0.0
+	for i, count := range counts {
+		if math.IsNaN(count) {
+			continue
+		}
+		variance += count * float64(i-mode) * float64(i-mode)
+	}
+	stddev := math.Sqrt(variance / (total - 1))
+	if stddev == 0 {
+		theoretical[mode] = total
+		return theoretical
+	}
+	for i := range theoretical {
+		z := (float64(i) - float64(mode)) / stddev
+		theoretical[i] = total * math.Exp(-z*z/2) / (stddev * math.Sqrt(2*math.Pi))
+	}
+	return theoretical
+}
+
+// LengthDistribution draws the histogram of the read lengths.
+func LengthDistribution(rows []types.Count) Figure {
+	figure := Figure{
+		Title:  "Distribution of sequence lengths over all sequences",
+		XLabel: "Sequence length (bp)",
+		YLabel: "Reads",
+	}
+	for _, row := range rows {
+		figure.Categories = append(figure.Categories, row.Value)
+		figure.Bars = append(figure.Bars, float64(row.Count))
+	}
+	figure.YMax = niceCeil(maxValue(figure.Bars))
+	return figure
+}
+
+// Duplication draws the share of the sequences per duplication level, before and after deduplication.
+func Duplication(levels types.DuplicationLevels) Figure {
+	figure := Figure{
+		Title:  "Percent of seqs remaining if deduplicated " + strconv.FormatFloat(float64(levels.TotalDeduplicatedPercentage), 'f', 2, 64) + "%",
+		XLabel: "Sequence duplication level",
+		YLabel: "Percentage",
+		YMax:   100,
+	}
+	deduplicated := Line{Name: "% Deduplicated sequences", Color: red}
+	total := Line{Name: "% Total sequences", Color: blue}
+	for _, level := range levels.Levels {
+		figure.Categories = append(figure.Categories, level.Level)
+		deduplicated.Values = append(deduplicated.Values, float64(level.PercentageDeduplicated))
+		total.Values = append(total.Values, float64(level.PercentageTotal))
+	}
+	figure.Lines = []Line{deduplicated, total}
+	return figure
+}
+
+// AdapterContent draws the cumulative percentage of reads with each adapter per position.
+func AdapterContent(content types.PositionalSeries) Figure {
+	figure := Figure{
+		Title:      "% Adapter",
+		XLabel:     "Position in read (bp)",
+		YLabel:     "Percentage",
+		Categories: content.Positions,
+		YMax:       100,
+	}
+	for i, series := range content.Series {
+		figure.Lines = append(figure.Lines, Line{Name: series.Name, Values: floats(series.Values), Color: palette[i%len(palette)]})
+	}
+	return figure
+}
+
+// maxValue returns the largest value that is not NaN, or 0.
+func maxValue(values []float64) float64 {
+	result := 0.0
+	for _, value := range values {
+		if !math.IsNaN(value) && value > result {
+			result = value
+		}
+	}
+	return result
+}
+
+// floats converts the values of a FastQC module, missing values stay NaN.
+func floats(values []types.Float) []float64 {
+	result := make([]float64, len(values))
+	for i, value := range values {
+		result[i] = float64(value)
+	}
+	return result
+}
+
+// niceCeil rounds value up to a round number, used as the top of the y axis.
+func niceCeil(value float64) float64 {
+	if value <= 0 {
+		return 1
+	}
+	step := niceStep(value / 5)
+	return math.Ceil(value/step) * step
+}
+
+// niceStep returns the 1, 2 or 5 times a power of ten closest above value.
+func niceStep(value float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, factor := range []float64{1, 2, 5, 10} {
+		if factor*magnitude >= value {
+			return factor * magnitude
+		}
+	}
+	return 10 * magnitude
+}
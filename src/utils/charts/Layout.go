@@ -0,0 +1,146 @@
+package charts
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// Margins around the plot area, in pixels.
+const (
+	marginLeft   = 70
+	marginRight  = 20
+	marginTop    = 50
+	marginBottom = 60
+	// minLabelSpacing is the minimum distance between two x axis labels.
+	minLabelSpacing = 48
+)
+
+// point is a position on the canvas, in pixels from the top left corner.
+type point struct {
+	x, y float64
+}
+
+// anchor aligns a text horizontally on its position.
+type anchor int
+
+const (
+	anchorStart anchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+// canvas is implemented by the SVG and PNG backends.
+// Text positions are on the baseline of the text.
+type canvas interface {
+	fillRect(x, y, w, h float64, c color.RGBA)
+	polyline(points []point, c color.RGBA, width float64, dashed bool)
+	text(x, y float64, s string, a anchor, c color.RGBA)
+}
+
+// draw lays out the figure on c.
+func (f Figure) draw(c canvas) {
+	plotX, plotY := float64(marginLeft), float64(marginTop)
+	plotW, plotH := float64(Width-marginLeft-marginRight), float64(Height-marginTop-marginBottom)
+	n := len(f.Categories)
+	slot := plotW / math.Max(float64(n), 1)
+	yMin, yMax := f.YMin, f.YMax
+	if yMax <= yMin {
+		yMax = yMin + 1
+	}
+	xAt := func(i int) float64 { return plotX + (float64(i)+0.5)*slot }
+	yAt := func(v float64) float64 {
+		v = math.Max(yMin, math.Min(yMax, v))
+		return plotY + plotH - (v-yMin)/(yMax-yMin)*plotH
+	}
+
+	c.fillRect(0, 0, Width, Height, white)
+	c.text(Width/2, 28, f.Title, anchorMiddle, black)
+
+	// Background zones and grid.
+	for _, band := range f.Bands {
+		top, bottom := yAt(band.To), yAt(band.From)
+		c.fillRect(plotX, top, plotW, bottom-top, band.Color)
+	}
+	step := niceStep((yMax - yMin) / 5)
+	decimals := int(math.Max(0, -math.Floor(math.Log10(step))))
+	for tick := math.Ceil(yMin/step) * step; tick <= yMax+step/1e6; tick += step {
+		y := yAt(tick)
+		if len(f.Bands) == 0 {
+			c.polyline([]point{{plotX, y}, {plotX + plotW, y}}, grey, 1, false)
+		}
+		c.polyline([]point{{plotX - 4, y}, {plotX, y}}, black, 1, false)
+		c.text(plotX-8, y+4, strconv.FormatFloat(tick, 'f', decimals, 64), anchorEnd, black)
+	}
+
+	// Data.
+	for i, bar := range f.Bars {
+		if math.IsNaN(bar) {
+			continue
+		}
+		top := yAt(bar)
+		c.fillRect(xAt(i)-slot*0.4, top, slot*0.8, yAt(yMin)-top, blue)
+	}
+	for i, box := range f.Boxes {
+		// Positions without data have NaN quartiles.
+		if !box.valid() {
+			continue
+		}
+		x, half := xAt(i), math.Max(slot*0.3, 1)
+		c.polyline([]point{{x, yAt(box.Low)}, {x, yAt(box.High)}}, black, 1, false)
+		c.polyline([]point{{x - half/2, yAt(box.Low)}, {x + half/2, yAt(box.Low)}}, black, 1, false)
+		c.polyline([]point{{x - half/2, yAt(box.High)}, {x + half/2, yAt(box.High)}}, black, 1, false)
+		top, bottom := yAt(box.Q3), yAt(box.Q1)
+		c.fillRect(x-half, top, 2*half, bottom-top, yellow)
+		c.polyline([]point{{x - half, top}, {x + half, top}, {x + half, bottom}, {x - half, bottom}, {x - half, top}}, black, 1, false)
+		c.polyline([]point{{x - half, yAt(box.Median)}, {x + half, yAt(box.Median)}}, red, 2, false)
+	}
+	for _, line := range f.Lines {
+		// NaN values split the line in several segments.
+		var points []point
+		for i, value := range line.Values {
+			if math.IsNaN(value) {
+				if len(points) > 1 {
+					c.polyline(points, line.Color, 2, line.Dashed)
+				}
+				points = nil
+				continue
+			}
+			points = append(points, point{xAt(i), yAt(value)})
+		}
+		if len(points) > 1 {
+			c.polyline(points, line.Color, 2, line.Dashed)
+		}
+	}
+
+	// Axes and labels.
+	c.polyline([]point{{plotX, plotY}, {plotX, plotY + plotH}, {plotX + plotW, plotY + plotH}}, black, 1, false)
+	every := int(math.Ceil(minLabelSpacing / slot))
+	for i, category := range f.Categories {
+		if i%every != 0 {
+			continue
+		}
+		c.polyline([]point{{xAt(i), plotY + plotH}, {xAt(i), plotY + plotH + 4}}, black, 1, false)
+		c.text(xAt(i), plotY+plotH+18, category, anchorMiddle, black)
+	}
+	c.text(plotX+plotW/2, Height-16, f.XLabel, anchorMiddle, black)
+	c.text(plotX, plotY-10, f.YLabel, anchorMiddle, black)
+
+	// Legend, in the top right corner of the plot.
+	legendY := plotY + 16
+	for _, line := range f.Lines {
+		if line.Name == "" {
+			continue
+		}
+		right := plotX + plotW - 8
+		c.text(right, legendY+4, line.Name, anchorEnd, black)
+		width := textWidth(line.Name)
+		c.polyline([]point{{right - width - 28, legendY}, {right - width - 8, legendY}}, line.Color, 2, line.Dashed)
+		legendY += 16
+	}
+}
+
+// textWidth estimates the width of s with the font of the PNG backend, also used by the SVG backend.
+func textWidth(s string) float64 {
+	return float64(len([]rune(s)) * glyphWidth)
+}
@@ -0,0 +1,105 @@
+package charts
+
+import (
+	"math"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// maxLegendEntries is the number of samples above which the overlaid charts have no legend.
+const maxLegendEntries = 10
+
+// Sample is the metrics of an analyzed file, named in the legend of the overlaid charts.
+type Sample struct {
+	Name    string
+	Metrics types.QCMetrics
+}
+
+// QualityOverlay draws the mean quality per base position of every sample.
+// The positions are those of the sample with the most of them, the other samples are matched by label.
+func QualityOverlay(samples []Sample) Figure {
+	figure := Figure{
+		Title:  "Mean quality scores",
+		XLabel: "Position in read (bp)",
+		YLabel: "Phred score",
+		YMax:   40,
+		Bands: []Band{
+			{From: 0, To: 20, Color: badZone},
+			{From: 20, To: 28, Color: poorZone},
+		},
+	}
+	for _, sample := range samples {
+		if len(sample.Metrics.PerBaseQuality) > len(figure.Categories) {
+			figure.Categories = figure.Categories[:0]
+			for _, row := range sample.Metrics.PerBaseQuality {
+				figure.Categories = append(figure.Categories, row.Base)
+			}
+		}
+	}
+	for i, sample := range samples {
+		means := map[string]float64{}
+		for _, row := range sample.Metrics.PerBaseQuality {
+			if !row.Mean.Valid() {
+				continue
+			}
+			means[row.Base] = float64(row.Mean)
+			figure.YMax = math.Max(figure.YMax, math.Ceil(float64(row.Mean)))
+		}
+		figure.Lines = append(figure.Lines, overlayLine(sample.Name, i, len(samples), figure.Categories, means))
+	}
+	figure.Bands = append(figure.Bands, Band{From: 28, To: figure.YMax, Color: goodZone})
+	return figure
+}
+
+// GCOverlay draws the GC distribution of every sample, as a percentage of its reads so that samples of different sizes compare.
+func GCOverlay(samples []Sample) Figure {
+	figure := Figure{
+		Title:  "Per sequence GC content",
+		XLabel: "Mean GC content (%)",
+		YLabel: "% of reads",
+	}
+	for _, sample := range samples {
+		if len(sample.Metrics.PerSequenceGC) > len(figure.Categories) {
+			figure.Categories = figure.Categories[:0]
+			for _, row := range sample.Metrics.PerSequenceGC {
+				figure.Categories = append(figure.Categories, row.Value)
+			}
+		}
+	}
+	top := 0.0
+	for i, sample := range samples {
+		total := 0.0
+		for _, row := range sample.Metrics.PerSequenceGC {
+			if row.Count.Valid() {
+				total += float64(row.Count)
+			}
+		}
+		shares := map[string]float64{}
+		for _, row := range sample.Metrics.PerSequenceGC {
+			if total > 0 && row.Count.Valid() {
+				shares[row.Value] = float64(row.Count) / total * 100
+			}
+		}
+		line := overlayLine(sample.Name, i, len(samples), figure.Categories, shares)
+		top = math.Max(top, maxValue(line.Values))
+		figure.Lines = append(figure.Lines, line)
+	}
+	figure.YMax = niceCeil(top)
+	return figure
+}
+
+// overlayLine builds the line of the index-th of count samples, NaN where it has no value for a category.
+func overlayLine(name string, index int, count int, categories []string, values map[string]float64) Line {
+	line := Line{Color: palette[index%len(palette)]}
+	if count <= maxLegendEntries {
+		line.Name = name
+	}
+	for _, category := range categories {
+		value, ok := values[category]
+		if !ok {
+			value = math.NaN()
+		}
+		line.Values = append(line.Values, value)
+	}
+	return line
+}
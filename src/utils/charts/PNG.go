@@ -0,0 +1,128 @@
+package charts
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// glyphWidth is the advance of the bitmap font embedded in the PNG backend.
+const glyphWidth = 7
+
+// PNG renders the figure as a PNG image.
+// The text is drawn with a bitmap font compiled into the binary, so no font has to be installed.
+func (f Figure) PNG() ([]byte, error) {
+	c := &pngCanvas{
+		image:      image.NewRGBA(image.Rect(0, 0, Width, Height)),
+		rasterizer: vector.NewRasterizer(Width, Height),
+	}
+	f.draw(c)
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, c.image); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// pngCanvas draws a figure on an RGBA image.
+type pngCanvas struct {
+	image      *image.RGBA
+	rasterizer *vector.Rasterizer
+}
+
+func (c *pngCanvas) fillRect(x, y, w, h float64, fill color.RGBA) {
+	rect := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	draw.Draw(c.image, rect, image.NewUniform(fill), image.Point{}, draw.Over)
+}
+
+func (c *pngCanvas) polyline(points []point, stroke color.RGBA, width float64, dashed bool) {
+	segments := make([][2]point, 0, len(points))
+	for i := 1; i < len(points); i++ {
+		segments = append(segments, [2]point{points[i-1], points[i]})
+	}
+	if dashed {
+		segments = dash(segments, 6, 4)
+	}
+
+	c.rasterizer.Reset(Width, Height)
+	c.rasterizer.DrawOp = draw.Over
+	drawn := false
+	for _, segment := range segments {
+		from, to := segment[0], segment[1]
+		// Axis-aligned segments are filled directly, which keeps them sharp.
+		if !dashed && (from.x == to.x || from.y == to.y) {
+			c.fillRect(math.Min(from.x, to.x)-width/2, math.Min(from.y, to.y)-width/2, math.Abs(to.x-from.x)+width, math.Abs(to.y-from.y)+width, stroke)
+			continue
+		}
+		length := math.Hypot(to.x-from.x, to.y-from.y)
+		if length == 0 {
+			continue
+		}
+		// The segment is drawn as a quad offset by half the width on both sides.
+		nx, ny := -(to.y-from.y)/length*width/2, (to.x-from.x)/length*width/2
+		c.rasterizer.MoveTo(float32(from.x+nx), float32(from.y+ny))
+		c.rasterizer.LineTo(float32(to.x+nx), float32(to.y+ny))
+		c.rasterizer.LineTo(float32(to.x-nx), float32(to.y-ny))
+		c.rasterizer.LineTo(float32(from.x-nx), float32(from.y-ny))
+		c.rasterizer.ClosePath()
+		drawn = true
+	}
+	if drawn {
+		c.rasterizer.Draw(c.image, c.image.Bounds(), image.NewUniform(stroke), image.Point{})
+	}
+}
+
+func (c *pngCanvas) text(x, y float64, s string, a anchor, fill color.RGBA) {
+	drawer := font.Drawer{Dst: c.image, Src: image.NewUniform(fill), Face: basicfont.Face7x13}
+	width := drawer.MeasureString(s)
+	switch a {
+	case anchorMiddle:
+		x -= float64(width.Round()) / 2
+	case anchorEnd:
+		x -= float64(width.Round())
+	}
+	drawer.Dot = fixed.P(int(math.Round(x)), int(math.Round(y)))
+	drawer.DrawString(s)
+}
+
+// dash splits the segments in dashes of length on separated by gaps of length off.
+func dash(segments [][2]point, on float64, off float64) [][2]point {
+	var dashes [][2]point
+	drawing, remaining := true, on
+	for _, segment := range segments {
+		from, to := segment[0], segment[1]
+		length := math.Hypot(to.x-from.x, to.y-from.y)
+		for position := 0.0; position < length; {
+			step := math.Min(remaining, length-position)
+			start := interpolate(from, to, position/length)
+			end := interpolate(from, to, (position+step)/length)
+			if drawing {
+				dashes = append(dashes, [2]point{start, end})
+			}
+			position += step
+			remaining -= step
+			if remaining <= 0 {
+				drawing = !drawing
+				remaining = off
+				if drawing {
+					remaining = on
+				}
+			}
+		}
+	}
+	return dashes
+}
+
+// interpolate returns the point at ratio t between from and to.
+func interpolate(from point, to point, t float64) point {
+	return point{from.x + (to.x-from.x)*t, from.y + (to.y-from.y)*t}
+}
@@ -0,0 +1,59 @@
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SVG renders the figure as a standalone SVG document.
+// The text uses the generic monospace family so that no particular font has to be installed.
+func (f Figure) SVG() []byte {
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.buffer, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="12">`+"\n", Width, Height, Width, Height)
+	f.draw(c)
+	c.buffer.WriteString("</svg>\n")
+	return c.buffer.Bytes()
+}
+
+// svgCanvas writes the SVG elements of a figure.
+type svgCanvas struct {
+	buffer bytes.Buffer
+}
+
+func (c *svgCanvas) fillRect(x, y, w, h float64, fill color.RGBA) {
+	fmt.Fprintf(&c.buffer, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n", number(x), number(y), number(w), number(h), hex(fill))
+}
+
+func (c *svgCanvas) polyline(points []point, stroke color.RGBA, width float64, dashed bool) {
+	coordinates := make([]string, len(points))
+	for i, p := range points {
+		coordinates[i] = number(p.x) + "," + number(p.y)
+	}
+	dash := ""
+	if dashed {
+		dash = ` stroke-dasharray="6 4"`
+	}
+	fmt.Fprintf(&c.buffer, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%s"%s/>`+"\n", strings.Join(coordinates, " "), hex(stroke), number(width), dash)
+}
+
+func (c *svgCanvas) text(x, y float64, s string, a anchor, fill color.RGBA) {
+	textAnchor := [...]string{"start", "middle", "end"}[a]
+	fmt.Fprintf(&c.buffer, `<text x="%s" y="%s" text-anchor="%s" fill="%s">`, number(x), number(y), textAnchor, hex(fill))
+	xml.EscapeText(&c.buffer, []byte(s))
+	c.buffer.WriteString("</text>\n")
+}
+
+// number formats a coordinate with at most two decimals.
+func number(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+// hex formats an opaque color as #rrggbb.
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,152 @@
+package checksum
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Digests are the checksums of a file.
+type Digests struct {
+	Size   int64
+	MD5    string
+	SHA256 string
+}
+
+// Hasher computes the digests of the bytes written to it, e.g. through an io.TeeReader on a stream being consumed.
+type Hasher struct {
+	size   int64
+	md5    hash.Hash
+	sha256 hash.Hash
+}
+
+// NewHasher returns a Hasher with nothing written yet.
+func NewHasher() *Hasher {
+	return &Hasher{md5: md5.New(), sha256: sha256.New()}
+}
+
+func (h *Hasher) Write(p []byte) (int, error) {
+	h.md5.Write(p)
+	h.sha256.Write(p)
+	h.size += int64(len(p))
+	return len(p), nil
+}
+
+// Digests returns the digests of the bytes written so far.
+func (h *Hasher) Digests() Digests {
+	return Digests{
+		Size:   h.size,
+		MD5:    hex.EncodeToString(h.md5.Sum(nil)),
+		SHA256: hex.EncodeToString(h.sha256.Sum(nil)),
+	}
+}
+
+// manifestNames are the names, in lower case, of the manifests listing several files.
+// Files ending with .md5 are manifests too, they often hold the checksum of a single file.
+var manifestNames = map[string]bool{
+	"md5sum.txt":  true,
+	"md5sums.txt": true,
+	"md5.txt":     true,
+}
+
+// Entry is the expected checksum of a file, as listed in a manifest.
+type Entry struct {
+	MD5 string
+	// Manifest is the name of the manifest listing the file.
+	Manifest string
+}
+
+// Manifest maps the files of a folder, relative to it and slash separated, to their expected checksum.
+type Manifest map[string]Entry
+
+var (
+	// gnuLine is written by md5sum, the path is preceded by a space for text mode or by * for binary mode.
+	gnuLine = regexp.MustCompile(`^([0-9a-fA-F]{32}) [ *](.+)$`)
+	// bsdLine is written by md5 on BSD and macOS, and by md5sum --tag.
+	bsdLine = regexp.MustCompile(`^MD5 ?\((.+)\) ?= ?([0-9a-fA-F]{32})$`)
+	// bareLine holds only a checksum, it applies to the file named after the manifest, e.g. sample.fastq.gz.md5.
+	bareLine = regexp.MustCompile(`^([0-9a-fA-F]{32})$`)
+)
+
+// Find reads the checksum manifests found in dir, e.g. md5sum.txt, MD5.txt or sample_R1.fastq.gz.md5.
+// Manifests are read in name order and the first entry of a file wins. Lines in other formats are ignored.
+func Find(dir string) (Manifest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := strings.ToLower(entry.Name())
+		if entry.Type().IsRegular() && (manifestNames[name] || strings.HasSuffix(name, ".md5")) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	manifest := Manifest{}
+	for _, name := range names {
+		if err := manifest.read(filepath.Join(dir, name)); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// read adds the entries of the manifest at manifestPath.
+func (m Manifest) read(manifestPath string) error {
+	file, err := os.Open(manifestPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	name := filepath.Base(manifestPath)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Manifests written on Windows may start with a byte order mark and use backslashes, the paths are cleaned below.
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		var sum, listed string
+		if match := gnuLine.FindStringSubmatch(line); match != nil {
+			sum, listed = match[1], match[2]
+		} else if match := bsdLine.FindStringSubmatch(line); match != nil {
+			sum, listed = match[2], match[1]
+		} else if match := bareLine.FindStringSubmatch(line); match != nil && strings.HasSuffix(strings.ToLower(name), ".md5") {
+			sum, listed = match[1], name[:len(name)-len(".md5")]
+		} else {
+			continue
+		}
+		listed = path.Clean(strings.ReplaceAll(listed, `\`, "/"))
+		if _, ok := m[listed]; !ok {
+			m[listed] = Entry{MD5: strings.ToLower(sum), Manifest: name}
+		}
+	}
+	return scanner.Err()
+}
+
+// Lookup returns the entry of the file named name in the folder of the manifest.
+// Entries listed with a folder, e.g. by a provider shipping a single manifest for the whole delivery, are matched
+// by their base name when it is unique.
+func (m Manifest) Lookup(name string) (Entry, bool) {
+	if entry, ok := m[name]; ok {
+		return entry, true
+	}
+	var found []Entry
+	for listed, entry := range m {
+		if path.Base(listed) == name {
+			found = append(found, entry)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], true
+	}
+	return Entry{}, false
+}
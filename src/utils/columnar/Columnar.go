@@ -0,0 +1,215 @@
+package columnar
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// SCHEMA_VERSION is stored in the metadata of every table under SCHEMA_VERSION_KEY.
+// It must be incremented whenever a column is renamed, removed or changes type; adding a metric does not change it.
+const (
+	SCHEMA_VERSION     = "1"
+	SCHEMA_VERSION_KEY = "fastqc.schema_version"
+)
+
+// Names of the tables, used for the file names.
+const (
+	FILE_METRICS     = "fastqc_file_metrics"
+	POSITION_METRICS = "fastqc_position_metrics"
+)
+
+// Module status values of the "status" metric of the file table.
+const (
+	STATUS_PASS = 0
+	STATUS_WARN = 1
+	STATUS_FAIL = 2
+)
+
+var metadata = arrow.NewMetadata([]string{SCHEMA_VERSION_KEY}, []string{SCHEMA_VERSION})
+
+// FileSchema is the schema of the per-file table: one row per file, module and metric.
+var FileSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "file", Type: arrow.BinaryTypes.String},
+	{Name: "module", Type: arrow.BinaryTypes.String},
+	{Name: "metric", Type: arrow.BinaryTypes.String},
+	{Name: "value", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+}, &metadata)
+
+// PositionSchema is the schema of the per-position table: one row per file, module, position and metric.
+// The position is written as FastQC does, e.g. "10-14"; its numeric bounds are null when it is not a number or range, e.g. ">10".
+var PositionSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "file", Type: arrow.BinaryTypes.String},
+	{Name: "module", Type: arrow.BinaryTypes.String},
+	{Name: "position", Type: arrow.BinaryTypes.String},
+	{Name: "position_start", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "position_end", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "metric", Type: arrow.BinaryTypes.String},
+	{Name: "value", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+}, &metadata)
+
+// FileMetrics builds the per-file table of files. The caller must release the record.
+func FileMetrics(files []types.FileReport) arrow.Record {
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, FileSchema)
+	defer builder.Release()
+	add := func(file string, module string, metric string, value float64) {
+		builder.Field(0).(*array.StringBuilder).Append(file)
+		builder.Field(1).(*array.StringBuilder).Append(module)
+		builder.Field(2).(*array.StringBuilder).Append(metric)
+		appendValue(builder.Field(3).(*array.Float64Builder), value)
+	}
+
+	for _, file := range files {
+		metrics := file.Metrics
+		add(file.Source, "basic_statistics", "total_sequences", float64(metrics.Basic.TotalSequences))
+		add(file.Source, "basic_statistics", "sequences_flagged_poor_quality", float64(metrics.Basic.SequencesFlaggedPoorQuality))
+		add(file.Source, "basic_statistics", "gc_percent", float64(metrics.Basic.GCPercent))
+		add(file.Source, "sequence_duplication_levels", "total_deduplicated_percentage", float64(metrics.Duplication.TotalDeduplicatedPercentage))
+
+		summary := metrics.Summary()
+		add(file.Source, "general_stats", "mean_quality", summary.MeanQuality)
+		add(file.Source, "general_stats", "percent_duplicates", summary.PercentDuplicates)
+		add(file.Source, "general_stats", "max_adapter_percent", summary.MaxAdapterPercent)
+		add(file.Source, "general_stats", "failed_modules", float64(summary.FailedModules))
+		add(file.Source, "general_stats", "warned_modules", float64(summary.WarnedModules))
+
+		for _, module := range metrics.Modules {
+			status, ok := statusValues[module.Status]
+			if !ok {
+				continue
+			}
+			add(file.Source, moduleKey(module.Name), "status", status)
+		}
+	}
+	return builder.NewRecord()
+}
+
+// statusValues encodes the module statuses as numbers.
+var statusValues = map[string]float64{
+	types.MODULE_PASS: STATUS_PASS,
+	types.MODULE_WARN: STATUS_WARN,
+	types.MODULE_FAIL: STATUS_FAIL,
+}
+
+// PositionMetrics builds the per-position table of files. The caller must release the record.
+func PositionMetrics(files []types.FileReport) arrow.Record {
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, PositionSchema)
+	defer builder.Release()
+	add := func(file string, module string, position string, metric string, value float64) {
+		builder.Field(0).(*array.StringBuilder).Append(file)
+		builder.Field(1).(*array.StringBuilder).Append(module)
+		builder.Field(2).(*array.StringBuilder).Append(position)
+		start, end, ok := positionBounds(position)
+		if ok {
+			builder.Field(3).(*array.Int64Builder).Append(start)
+			builder.Field(4).(*array.Int64Builder).Append(end)
+		} else {
+			builder.Field(3).(*array.Int64Builder).AppendNull()
+			builder.Field(4).(*array.Int64Builder).AppendNull()
+		}
+		builder.Field(5).(*array.StringBuilder).Append(metric)
+		appendValue(builder.Field(6).(*array.Float64Builder), value)
+	}
+	addCounts := func(file string, module string, counts []types.Count) {
+		for _, row := range counts {
+			add(file, module, row.Value, "count", float64(row.Count))
+		}
+	}
+
+	for _, file := range files {
+		metrics := file.Metrics
+		for _, row := range metrics.PerBaseQuality {
+			module := "per_base_sequence_quality"
+			add(file.Source, module, row.Base, "mean", float64(row.Mean))
+			add(file.Source, module, row.Base, "median", float64(row.Median))
+			add(file.Source, module, row.Base, "lower_quartile", float64(row.LowerQuartile))
+			add(file.Source, module, row.Base, "upper_quartile", float64(row.UpperQuartile))
+			add(file.Source, module, row.Base, "percentile_10", float64(row.Percentile10))
+			add(file.Source, module, row.Base, "percentile_90", float64(row.Percentile90))
+		}
+		addCounts(file.Source, "per_sequence_quality_scores", metrics.PerSequenceQuality)
+		addCounts(file.Source, "per_sequence_gc_content", metrics.PerSequenceGC)
+		addCounts(file.Source, "sequence_length_distribution", metrics.LengthDistribution)
+		for _, level := range metrics.Duplication.Levels {
+			add(file.Source, "sequence_duplication_levels", level.Level, "percentage_deduplicated", float64(level.PercentageDeduplicated))
+			add(file.Source, "sequence_duplication_levels", level.Level, "percentage_total", float64(level.PercentageTotal))
+		}
+		for _, series := range metrics.AdapterContent.Series {
+			for i, value := range series.Values {
+				add(file.Source, "adapter_content", metrics.AdapterContent.Positions[i], series.Name, float64(value))
+			}
+		}
+	}
+	return builder.NewRecord()
+}
+
+// WriteParquet writes record to w as a Snappy compressed Parquet file.
+// The schema version is stored in the key-value metadata of the file.
+func WriteParquet(w io.Writer, record arrow.Record) error {
+	properties := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+	// The writer is wrapped so that closing the Parquet file does not close w.
+	writer, err := pqarrow.NewFileWriter(record.Schema(), struct{ io.Writer }{w}, properties, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+	if err != nil {
+		return err
+	}
+	if err := writer.Write(record); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// WriteArrow writes record to w as an Arrow IPC file.
+func WriteArrow(w io.Writer, record arrow.Record) error {
+	writer, err := ipc.NewFileWriter(w, ipc.WithSchema(record.Schema()))
+	if err != nil {
+		return err
+	}
+	if err := writer.Write(record); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// appendValue appends value, or null for the NaN written by FastQC when a position has no data.
+func appendValue(builder *array.Float64Builder, value float64) {
+	if math.IsNaN(value) {
+		builder.AppendNull()
+		return
+	}
+	builder.Append(value)
+}
+
+// positionBounds reads a position or a range of positions, e.g. 10-14.
+func positionBounds(position string) (int64, int64, bool) {
+	from, to, isRange := strings.Cut(position, "-")
+	start, err := strconv.ParseInt(from, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !isRange {
+		return start, start, true
+	}
+	end, err := strconv.ParseInt(to, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// moduleKey converts a module name into a column friendly key, e.g. per_base_sequence_quality.
+func moduleKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), " ", "_")
+}
@@ -0,0 +1,76 @@
+package dashboard
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/charts"
+)
+
+//go:embed dashboard.html
+var page string
+
+// pageTemplate renders the dashboard. Everything, including the charts, is inlined so that the file works offline.
+var pageTemplate = template.Must(template.New("dashboard").Parse(page))
+
+// Sample is an analyzed file shown in the dashboard.
+type Sample struct {
+	Name    string
+	Metrics types.QCMetrics
+	// Report is the link to the FastQC HTML report of the file, relative to the dashboard.
+	Report string
+}
+
+// view is the data of the template.
+type view struct {
+	Title   string
+	Samples []sampleView
+	Modules []string
+	Charts  []template.HTML
+}
+
+// sampleView is a row of the tables.
+type sampleView struct {
+	Name     string
+	Report   string
+	Stats    types.GeneralStats
+	Statuses []string
+}
+
+// Write renders the aggregate report of samples to w: a sortable table of the general statistics,
+// a heatmap of the module statuses, the overlaid per-base quality and GC curves, and links to the per-file reports.
+func Write(w io.Writer, title string, samples []Sample) error {
+	data := view{Title: title}
+
+	// The modules are listed in the order FastQC runs them, as found in the files.
+	seen := map[string]bool{}
+	for _, sample := range samples {
+		for _, module := range sample.Metrics.Modules {
+			if !seen[module.Name] {
+				seen[module.Name] = true
+				data.Modules = append(data.Modules, module.Name)
+			}
+		}
+	}
+
+	overlay := make([]charts.Sample, 0, len(samples))
+	for _, sample := range samples {
+		row := sampleView{Name: sample.Name, Report: sample.Report, Stats: sample.Metrics.Summary()}
+		for _, module := range data.Modules {
+			row.Statuses = append(row.Statuses, sample.Metrics.Status(module))
+		}
+		data.Samples = append(data.Samples, row)
+		overlay = append(overlay, charts.Sample{Name: sample.Name, Metrics: sample.Metrics})
+	}
+
+	if len(samples) > 0 {
+		// The SVG documents are generated by the charts package, which escapes their text.
+		data.Charts = []template.HTML{
+			template.HTML(charts.QualityOverlay(overlay).SVG()),
+			template.HTML(charts.GCOverlay(overlay).SVG()),
+		}
+	}
+	return pageTemplate.Execute(w, data)
+}
@@ -0,0 +1,104 @@
+package drift
+
+import (
+	"math"
+	"sort"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+const (
+	// MIN_BASELINE is the number of earlier files needed before a metric is checked.
+	MIN_BASELINE = 10
+	// WINDOW is the number of most recent earlier runs whose files form the baseline.
+	WINDOW = 100
+	// THRESHOLD is the absolute score above which a value is an outlier, as recommended by Iglewicz and Hoaglin for the robust z-score.
+	THRESHOLD = 3.5
+)
+
+// madScale makes the median absolute deviation comparable to a standard deviation for normal data.
+const madScale = 0.6745
+
+// Metric is a scalar metric of an analyzed file checked for drift.
+type Metric struct {
+	Name  string
+	Value func(types.QCFile) float64
+}
+
+// Metrics are the metrics checked for drift.
+var Metrics = []Metric{
+	{"total_reads", func(f types.QCFile) float64 { return float64(f.TotalReads) }},
+	{"gc_percent", func(f types.QCFile) float64 { return f.GCPercent }},
+	{"mean_quality", func(f types.QCFile) float64 { return f.MeanQuality }},
+	{"q30_fraction", func(f types.QCFile) float64 { return f.Q30Fraction }},
+	{"duplication_percent", func(f types.QCFile) float64 { return f.DuplicationPercent }},
+	{"adapter_percent", func(f types.QCFile) float64 { return f.AdapterPercent }},
+}
+
+// Detect compares the metrics of current with the baseline formed by earlier files.
+//
+// Each metric is scored with the robust z-score 0.6745 * (value - median) / MAD. When more than half of the
+// baseline shares the same value the MAD is zero, and the classic z-score against the mean and standard deviation
+// is used instead. A metric that never varied in the baseline is not scored.
+func Detect(baseline []types.QCFile, current types.QCFile) []types.Outlier {
+	if len(baseline) < MIN_BASELINE {
+		return nil
+	}
+	var outliers []types.Outlier
+	for _, metric := range Metrics {
+		values := make([]float64, len(baseline))
+		for i, file := range baseline {
+			values[i] = metric.Value(file)
+		}
+		value := metric.Value(current)
+		outlier := types.Outlier{Source: current.Source, Metric: metric.Name, Value: value, BaselineSize: len(values)}
+
+		median := median(values)
+		deviations := make([]float64, len(values))
+		for i, v := range values {
+			deviations[i] = math.Abs(v - median)
+		}
+		if mad := medianOf(deviations); mad > 0 {
+			outlier.Method, outlier.Center, outlier.Score = types.DRIFT_MAD, median, madScale*(value-median)/mad
+		} else {
+			mean, stddev := meanStddev(values)
+			if stddev == 0 {
+				continue
+			}
+			outlier.Method, outlier.Center, outlier.Score = types.DRIFT_ZSCORE, mean, (value-mean)/stddev
+		}
+		if math.Abs(outlier.Score) > THRESHOLD {
+			outliers = append(outliers, outlier)
+		}
+	}
+	return outliers
+}
+
+// median returns the median of a copy of values.
+func median(values []float64) float64 {
+	return medianOf(append([]float64(nil), values...))
+}
+
+// medianOf returns the median of values, which are sorted in place.
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[middle-1] + values[middle]) / 2
+	}
+	return values[middle]
+}
+
+// meanStddev returns the mean and the sample standard deviation of values.
+func meanStddev(values []float64) (float64, float64) {
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)-1))
+}
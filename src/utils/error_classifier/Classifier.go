@@ -0,0 +1,162 @@
+package error_classifier
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+
+	exceptionManager "github.com/CodeClarityCE/utility-types/exceptions"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// javaMissingDescription is shared between the output marker rule and the exit code check.
+const javaMissingDescription = "FastQC could not start because no Java runtime is available. Install a Java runtime (11 or later) in the plugin image and make sure it is on the PATH."
+
+// truncatedGzipDescription is shared between the gzip markers and the EOFException raised while reading a compressed FASTQ.
+const truncatedGzipDescription = "One of the compressed FASTQ files is truncated or corrupted. Upload the file again and check its checksum against the one provided by the sequencing facility."
+
+// rule associates a set of markers found in the FastQC output with the error reported to the user.
+type rule struct {
+	errorType exceptionManager.ERROR_TYPE
+	markers   []string
+	// context, when set, must also be found in the output for a marker to match; it narrows generic markers.
+	context     []string
+	publicError string
+}
+
+// matches reports whether the lowered output holds one of the markers of r, and one of its context strings if any.
+func (r rule) matches(lowered string) bool {
+	return containsAny(lowered, r.markers) && (len(r.context) == 0 || containsAny(lowered, r.context))
+}
+
+// containsAny reports whether s contains one of the substrings.
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// rules are evaluated in order; the first matching rule wins.
+// More specific failures are listed first because a single run can print several of these markers
+// (e.g. a truncated gzip is reported by Java as an EOFException wrapped in a format error).
+var rules = []rule{
+	{
+		errorType: types.FASTQC_JAVA_MISSING,
+		markers: []string{
+			"can't exec \"java\"",
+			"java: not found",
+			"java: command not found",
+			"unable to locate a java runtime",
+		},
+		publicError: javaMissingDescription,
+	},
+	{
+		errorType: types.FASTQC_OUT_OF_MEMORY,
+		markers: []string{
+			"java.lang.outofmemoryerror",
+			"gc overhead limit exceeded",
+			"could not reserve enough space for object heap",
+		},
+		publicError: "FastQC ran out of memory. Increase the memory available to the plugin or reduce the number of files analysed at once.",
+	},
+	{
+		errorType: types.FASTQC_DISK_FULL,
+		markers: []string{
+			"no space left on device",
+			"disk quota exceeded",
+		},
+		publicError: "FastQC could not write its reports because the disk is full. Free some space on the results volume and run the analysis again.",
+	},
+	{
+		errorType: types.FASTQC_PERMISSION_DENIED,
+		markers: []string{
+			"permission denied",
+			"accessdeniedexception",
+		},
+		publicError: "FastQC was not allowed to read the input files or write its reports. Check the permissions of the sample folder.",
+	},
+	{
+		errorType: types.FASTQC_TRUNCATED_GZIP,
+		markers: []string{
+			"unexpected end of zlib input stream",
+			"java.util.zip.zipexception",
+			"not in gzip format",
+			"corrupt gzip trailer",
+		},
+		publicError: truncatedGzipDescription,
+	},
+	{
+		// Any Java stream can end early, only the decompression or the FASTQ reader frames point to the input file.
+		errorType:   types.FASTQC_TRUNCATED_GZIP,
+		markers:     []string{"java.io.eofexception"},
+		context:     []string{"gzipinputstream", "inflaterinputstream", "fastqfile"},
+		publicError: truncatedGzipDescription,
+	},
+	{
+		errorType: types.FASTQC_INVALID_FORMAT,
+		markers: []string{
+			"sequenceformatexception",
+			"didn't start with '@'",
+			"ran out of data in the middle of a fastq entry",
+			"unknown file format",
+			"isn't a supported format",
+		},
+		publicError: "One of the input files is not a valid FASTQ, SAM or BAM file. Check that the files are complete sequencing reads in a supported format.",
+	},
+}
+
+// Classify turns a failed FastQC execution into an error with an actionable public description.
+//
+// Parameters:
+//
+//	output: The combined stdout and stderr of the FastQC process.
+//	err: The error returned when running the process.
+//
+// Returns:
+//
+//	An error whose private part keeps the raw output and whose public part explains how to fix the problem.
+//	Unrecognised failures are reported as a GENERIC_ERROR.
+func Classify(output string, err error) exceptionManager.Error {
+	private := output
+	if private == "" && err != nil {
+		private = err.Error()
+	}
+
+	// The fastqc wrapper itself is missing from the PATH.
+	if errors.Is(err, exec.ErrNotFound) {
+		return NewError(types.FASTQC_NOT_INSTALLED, private, "The FastQC executable could not be found. Make sure FastQC is installed in the plugin image and available on the PATH.")
+	}
+
+	lowered := strings.ToLower(output)
+	for _, r := range rules {
+		if r.matches(lowered) {
+			return NewError(r.errorType, private, r.publicError)
+		}
+	}
+
+	// The shell reports exit code 127 when the java binary used by the wrapper cannot be found.
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) && exitError.ExitCode() == 127 {
+		return NewError(types.FASTQC_JAVA_MISSING, private, javaMissingDescription)
+	}
+
+	return NewError(exceptionManager.GENERIC_ERROR, private, "The FastQC script failed to execute")
+}
+
+// newError builds an exceptionManager.Error sharing the same type for its private and public parts.
+func NewError(errorType exceptionManager.ERROR_TYPE, private string, public string) exceptionManager.Error {
+	return exceptionManager.Error{
+		Private: exceptionManager.ErrorContent{
+			Description: private,
+			Type:        errorType,
+		},
+		Public: exceptionManager.ErrorContent{
+			Description: public,
+			Type:        errorType,
+		},
+	}
+}
@@ -0,0 +1,254 @@
+package fastqc_data
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// Module names as written in fastqc_data.txt.
+const (
+	BASIC_STATISTICS          = "Basic Statistics"
+	PER_BASE_QUALITY          = "Per base sequence quality"
+	PER_SEQUENCE_QUALITY      = "Per sequence quality scores"
+	PER_SEQUENCE_GC_CONTENT   = "Per sequence GC content"
+	SEQUENCE_LENGTH           = "Sequence Length Distribution"
+	DUPLICATION_LEVELS        = "Sequence Duplication Levels"
+	OVERREPRESENTED_SEQUENCES = "Overrepresented sequences"
+	ADAPTER_CONTENT           = "Adapter Content"
+)
+
+// numericModules are the parsed modules whose columns after the first are all numbers.
+var numericModules = map[string]bool{
+	PER_BASE_QUALITY:        true,
+	PER_SEQUENCE_QUALITY:    true,
+	PER_SEQUENCE_GC_CONTENT: true,
+	SEQUENCE_LENGTH:         true,
+	DUPLICATION_LEVELS:      true,
+	ADAPTER_CONTENT:         true,
+}
+
+// ReadArchive parses <stem>/fastqc_data.txt from the FastQC archive at zipPath.
+func ReadArchive(zipPath string, stem string) (types.QCMetrics, error) {
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return types.QCMetrics{}, err
+	}
+	defer archive.Close()
+
+	name := path.Join(stem, "fastqc_data.txt")
+	for _, entry := range archive.File {
+		if entry.Name != name {
+			continue
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return types.QCMetrics{}, err
+		}
+		defer reader.Close()
+		return Parse(reader)
+	}
+	return types.QCMetrics{}, fmt.Errorf("%s not found in %s", name, zipPath)
+}
+
+// Parse reads the content of a fastqc_data.txt file.
+// Modules that are not used by the plugin are only recorded with their status.
+func Parse(r io.Reader) (types.QCMetrics, error) {
+	var metrics types.QCMetrics
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var module string
+	var header []string
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "##FastQC"):
+			metrics.Version = strings.TrimSpace(strings.TrimPrefix(line, "##FastQC"))
+			continue
+		case line == ">>END_MODULE":
+			module, header = "", nil
+			continue
+		case strings.HasPrefix(line, ">>"):
+			fields := strings.Split(strings.TrimPrefix(line, ">>"), "\t")
+			module = fields[0]
+			status := ""
+			if len(fields) > 1 {
+				status = fields[1]
+			}
+			metrics.Modules = append(metrics.Modules, types.ModuleStatus{Name: module, Status: status})
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if strings.HasPrefix(line, "#") {
+			// The duplication module starts with a value line before its header.
+			if module == DUPLICATION_LEVELS && fields[0] == "#Total Deduplicated Percentage" && len(fields) > 1 {
+				value, err := parseFloat(fields[1])
+				if err != nil {
+					return metrics, fmt.Errorf("line %d: %w", lineNumber, err)
+				}
+				metrics.Duplication.TotalDeduplicatedPercentage = value
+				continue
+			}
+			header = fields
+			header[0] = strings.TrimPrefix(header[0], "#")
+			if module == ADAPTER_CONTENT {
+				for _, name := range header[1:] {
+					metrics.AdapterContent.Series = append(metrics.AdapterContent.Series, types.Series{Name: name})
+				}
+			}
+			continue
+		}
+
+		if err := parseRow(&metrics, module, fields); err != nil {
+			return metrics, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return metrics, err
+	}
+	if metrics.Version == "" {
+		return metrics, fmt.Errorf("not a FastQC data file")
+	}
+	return metrics, nil
+}
+
+// parseRow decodes a data row of module into metrics.
+func parseRow(metrics *types.QCMetrics, module string, fields []string) error {
+	values, err := parseFloats(fields[1:], numericModules[module])
+	if err != nil {
+		return fmt.Errorf("%s: %w", module, err)
+	}
+
+	switch module {
+	case BASIC_STATISTICS:
+		if len(fields) < 2 {
+			return fmt.Errorf("%s: missing value", module)
+		}
+		return parseBasicStatistic(&metrics.Basic, fields[0], fields[1])
+	case PER_BASE_QUALITY:
+		if len(values) < 6 {
+			return fmt.Errorf("%s: expected 7 columns, got %d", module, len(fields))
+		}
+		metrics.PerBaseQuality = append(metrics.PerBaseQuality, types.BaseQuality{
+			Base:          fields[0],
+			Mean:          values[0],
+			Median:        values[1],
+			LowerQuartile: values[2],
+			UpperQuartile: values[3],
+			Percentile10:  values[4],
+			Percentile90:  values[5],
+		})
+	case PER_SEQUENCE_QUALITY, PER_SEQUENCE_GC_CONTENT, SEQUENCE_LENGTH:
+		if len(values) < 1 {
+			return fmt.Errorf("%s: expected 2 columns, got %d", module, len(fields))
+		}
+		count := types.Count{Value: fields[0], Count: values[0]}
+		switch module {
+		case PER_SEQUENCE_QUALITY:
+			metrics.PerSequenceQuality = append(metrics.PerSequenceQuality, count)
+		case PER_SEQUENCE_GC_CONTENT:
+			metrics.PerSequenceGC = append(metrics.PerSequenceGC, count)
+		default:
+			metrics.LengthDistribution = append(metrics.LengthDistribution, count)
+		}
+	case DUPLICATION_LEVELS:
+		if len(values) < 2 {
+			return fmt.Errorf("%s: expected 3 columns, got %d", module, len(fields))
+		}
+		metrics.Duplication.Levels = append(metrics.Duplication.Levels, types.DuplicationLevel{
+			Level:                  fields[0],
+			PercentageDeduplicated: values[0],
+			PercentageTotal:        values[1],
+		})
+	case ADAPTER_CONTENT:
+		series := metrics.AdapterContent.Series
+		if len(values) != len(series) {
+			return fmt.Errorf("%s: expected %d columns, got %d", module, len(series)+1, len(fields))
+		}
+		metrics.AdapterContent.Positions = append(metrics.AdapterContent.Positions, fields[0])
+		for i, value := range values {
+			series[i].Values = append(series[i].Values, value)
+		}
+	case OVERREPRESENTED_SEQUENCES:
+		if len(fields) < 4 {
+			return fmt.Errorf("%s: expected 4 columns, got %d", module, len(fields))
+		}
+		count, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", module, err)
+		}
+		percentage, err := parseFloat(fields[2])
+		if err != nil {
+			return fmt.Errorf("%s: %w", module, err)
+		}
+		metrics.Overrepresented = append(metrics.Overrepresented, types.Overrepresented{
+			Sequence:   fields[0],
+			Count:      count,
+			Percentage: percentage,
+			Source:     fields[3],
+		})
+	}
+	return nil
+}
+
+// parseBasicStatistic sets the measure of the "Basic Statistics" module.
+func parseBasicStatistic(basic *types.BasicStatistics, measure string, value string) error {
+	var err error
+	switch measure {
+	case "Filename":
+		basic.Filename = value
+	case "File type":
+		basic.FileType = value
+	case "Encoding":
+		basic.Encoding = value
+	case "Total Sequences":
+		basic.TotalSequences, err = strconv.ParseInt(value, 10, 64)
+	case "Total Bases":
+		basic.TotalBases = value
+	case "Sequences flagged as poor quality":
+		basic.SequencesFlaggedPoorQuality, err = strconv.ParseInt(value, 10, 64)
+	case "Sequence length":
+		basic.SequenceLength = value
+	case "%GC":
+		basic.GCPercent, err = parseFloat(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", BASIC_STATISTICS, measure, err)
+	}
+	return nil
+}
+
+// parseFloats converts the numeric columns of a row. It returns nil without error when numeric is false.
+func parseFloats(fields []string, numeric bool) ([]types.Float, error) {
+	if !numeric {
+		return nil, nil
+	}
+	values := make([]types.Float, len(fields))
+	for i, field := range fields {
+		value, err := parseFloat(field)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// parseFloat converts a value written by FastQC, which uses NaN for positions without data.
+// NaN is kept as a missing value, see types.Float.
+func parseFloat(field string) (types.Float, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+	return types.Float(value), err
+}
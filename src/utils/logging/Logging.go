@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Level is the minimum level of the records written by the default logger.
+// It can be changed while the plugin runs.
+var Level = new(slog.LevelVar)
+
+// contextKey is the key under which the logger is stored in a context.
+type contextKey struct{}
+
+// Init installs a JSON logger writing to stderr as the default slog and log logger.
+// The level is info until it is set from the settings, see settings.Settings.LogLevel.
+func Init() {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: Level})))
+}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger if there is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger includes the given attributes.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// LineWriter is an io.Writer logging every line written to it as a separate record.
+// It is used to capture the output of subprocesses.
+type LineWriter struct {
+	ctx     context.Context
+	logger  *slog.Logger
+	level   slog.Level
+	message string
+	mu      sync.Mutex
+	buffer  bytes.Buffer
+}
+
+// NewLineWriter creates a LineWriter logging each line with message and the line in the "line" attribute.
+func NewLineWriter(ctx context.Context, level slog.Level, message string) *LineWriter {
+	return &LineWriter{ctx: ctx, logger: FromContext(ctx), level: level, message: message}
+}
+
+// Write logs the complete lines of p and keeps the remainder until the next write or Flush.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buffer.Write(p)
+	for {
+		line, err := w.buffer.ReadString('\n')
+		if err != nil {
+			// Incomplete line, put it back until the rest is written.
+			w.buffer.WriteString(line)
+			break
+		}
+		w.log(line)
+	}
+	return len(p), nil
+}
+
+// Flush logs the last line if it was not terminated by a newline.
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buffer.Len() > 0 {
+		w.log(w.buffer.String())
+		w.buffer.Reset()
+	}
+}
+
+func (w *LineWriter) log(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return
+	}
+	w.logger.Log(w.ctx, w.level, w.message, "line", line)
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Job statuses recorded by JobsTotal in addition to the analysis statuses.
+const (
+	JOB_CANCELLED = "cancelled"
+	JOB_ERROR     = "error"
+)
+
+// Results of a lookup recorded by CacheLookups.
+const (
+	CACHE_HIT  = "hit"
+	CACHE_MISS = "miss"
+)
+
+var (
+	// JobsTotal counts the analyses processed by the plugin, labelled by their final status.
+	JobsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fastqc_jobs_total",
+		Help: "Number of analyses processed, by final status.",
+	}, []string{"status"})
+
+	// QCDuration measures how long the QC of an analysis takes.
+	QCDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "fastqc_qc_duration_seconds",
+		Help:    "Duration of the QC of an analysis.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 16),
+	})
+
+	// BytesProcessed counts the size of the input files handed to FastQC.
+	BytesProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "fastqc_bytes_processed_total",
+		Help: "Total size of the input files analyzed by FastQC.",
+	})
+
+	// ExitCodes counts the FastQC process exit codes, -1 means the process could not be started or was killed.
+	ExitCodes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fastqc_exit_codes_total",
+		Help: "Number of FastQC executions, by exit code.",
+	}, []string{"code"})
+
+	// QueueMessages reports the number of messages waiting in the dispatcher queue.
+	QueueMessages = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "fastqc_queue_messages",
+		Help: "Number of messages waiting in the queue.",
+	})
+
+	// QueueLag measures the time messages spent in the queue before being consumed.
+	// It is only observed for messages published with a timestamp.
+	QueueLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "fastqc_queue_lag_seconds",
+		Help:    "Time between the publication and the consumption of a message.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 4, 10),
+	})
+
+	// ActiveWorkers reports the number of analyses currently running.
+	ActiveWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "fastqc_active_workers",
+		Help: "Number of analyses currently running.",
+	})
+
+	// CacheLookups counts the lookups of per-file QC results in the cache, by result.
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fastqc_cache_lookups_total",
+		Help: "Number of per-file QC results looked up in the cache, by result.",
+	}, []string{"result"})
+)
@@ -0,0 +1,264 @@
+package multiqc
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// File names used by MultiQC in its data directory.
+const (
+	DATA_DIR      = "multiqc_data"
+	JSON_FILE     = "multiqc_data.json"
+	FASTQC_TSV    = "multiqc_fastqc.txt"
+	GENERAL_STATS = "multiqc_general_stats.txt"
+)
+
+// basicColumns are the "Basic Statistics" measures copied to the multiqc_fastqc table, in MultiQC order.
+var basicColumns = []string{"Filename", "File type", "Encoding", "Total Sequences", "Total Bases", "Sequences flagged as poor quality", "Sequence length", "%GC"}
+
+// statusColumns are the FastQC modules whose status is reported, in MultiQC order.
+var statusColumns = []string{
+	"Basic Statistics",
+	"Per base sequence quality",
+	"Per tile sequence quality",
+	"Per sequence quality scores",
+	"Per base sequence content",
+	"Per sequence GC content",
+	"Per base N content",
+	"Sequence Length Distribution",
+	"Sequence Duplication Levels",
+	"Overrepresented sequences",
+	"Adapter Content",
+}
+
+// Sample is an analyzed file exported to MultiQC.
+type Sample struct {
+	// Source is the name of the analyzed file, the sample name is derived from it like MultiQC does.
+	Source  string
+	Metrics types.QCMetrics
+}
+
+// SampleName cleans a FASTQ file name into a MultiQC sample name, e.g. sample_R1.fastq.gz becomes sample_R1.
+func SampleName(source string) string {
+	for _, extension := range []string{".gz", ".bz2", ".fastq", ".fq", ".bam", ".sam"} {
+		source = strings.TrimSuffix(source, extension)
+	}
+	return source
+}
+
+// FastQCTable returns the header and rows of multiqc_fastqc.txt, sorted by sample name.
+func FastQCTable(samples []Sample) ([]string, [][]string) {
+	header := []string{"Sample"}
+	header = append(header, basicColumns...)
+	header = append(header, "total_deduplicated_percentage", "avg_sequence_length", "median_sequence_length")
+	for _, module := range statusColumns {
+		header = append(header, moduleKey(module))
+	}
+
+	rows := make([][]string, 0, len(samples))
+	for _, sample := range sortedSamples(samples) {
+		fields := rawData(sample.Metrics)
+		row := []string{SampleName(sample.Source)}
+		for _, column := range header[1:] {
+			row = append(row, format(fields[column]))
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+// GeneralStatsTable returns the header and rows of multiqc_general_stats.txt, with the columns prefixed by the module like MultiQC does.
+func GeneralStatsTable(samples []Sample) ([]string, [][]string) {
+	header := []string{"Sample"}
+	for _, key := range generalStatsKeys {
+		header = append(header, "FastQC_mqc-generalstats-fastqc-"+key)
+	}
+	rows := make([][]string, 0, len(samples))
+	for _, sample := range sortedSamples(samples) {
+		stats := generalStats(sample.Metrics)
+		row := []string{SampleName(sample.Source)}
+		for _, key := range generalStatsKeys {
+			row = append(row, format(stats[key]))
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+// WriteTSV writes a table as tab separated values.
+func WriteTSV(w io.Writer, header []string, rows [][]string) error {
+	if _, err := io.WriteString(w, strings.Join(header, "\t")+"\n"); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := io.WriteString(w, strings.Join(row, "\t")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the subset of multiqc_data.json describing the FastQC module:
+// the general statistics, the raw data saved by the module and the data sources.
+func WriteJSON(w io.Writer, samples []Sample) error {
+	generalStatsData := map[string]map[string]any{}
+	rawDataBySample := map[string]map[string]any{}
+	sources := map[string]string{}
+	for _, sample := range samples {
+		name := SampleName(sample.Source)
+		generalStatsData[name] = generalStats(sample.Metrics)
+		rawDataBySample[name] = rawData(sample.Metrics)
+		sources[name] = sample.Source
+	}
+
+	document := map[string]any{
+		"report_general_stats_data": []any{generalStatsData},
+		"report_general_stats_headers": []any{map[string]any{
+			"percent_duplicates":     map[string]any{"title": "% Dups", "description": "% Duplicate Reads", "suffix": "%", "max": 100, "min": 0},
+			"percent_gc":             map[string]any{"title": "% GC", "description": "Average % GC Content", "suffix": "%", "max": 100, "min": 0},
+			"avg_sequence_length":    map[string]any{"title": "Average Read Length", "description": "Average Read Length (bp)", "suffix": " bp"},
+			"median_sequence_length": map[string]any{"title": "Median Read Length", "description": "Median Read Length (bp)", "suffix": " bp"},
+			"percent_fails":          map[string]any{"title": "% Failed", "description": "Percentage of modules failed in FastQC report", "suffix": "%", "max": 100, "min": 0},
+			"total_sequences":        map[string]any{"title": "Seqs", "description": "Total Sequences"},
+		}},
+		"report_saved_raw_data": map[string]any{
+			"multiqc_fastqc": rawDataBySample,
+		},
+		"report_data_sources": map[string]any{
+			"FastQC": map[string]any{"all_sections": sources},
+		},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(document)
+}
+
+// generalStatsKeys are the columns of the FastQC general statistics, in MultiQC order.
+var generalStatsKeys = []string{"percent_duplicates", "percent_gc", "avg_sequence_length", "median_sequence_length", "percent_fails", "total_sequences"}
+
+// generalStats computes the FastQC general statistics of MultiQC.
+func generalStats(metrics types.QCMetrics) map[string]any {
+	summary := metrics.Summary()
+	average, median := lengthStatistics(metrics.LengthDistribution)
+	percentFails := 0.0
+	if len(metrics.Modules) > 0 {
+		percentFails = float64(summary.FailedModules) / float64(len(metrics.Modules)) * 100
+	}
+	return map[string]any{
+		"percent_duplicates":     summary.PercentDuplicates,
+		"percent_gc":             summary.GCPercent,
+		"avg_sequence_length":    average,
+		"median_sequence_length": median,
+		"percent_fails":          percentFails,
+		"total_sequences":        float64(summary.TotalSequences),
+	}
+}
+
+// rawData returns the values MultiQC saves for a FastQC report, keyed by column.
+func rawData(metrics types.QCMetrics) map[string]any {
+	average, median := lengthStatistics(metrics.LengthDistribution)
+	fields := map[string]any{
+		"Filename":                          metrics.Basic.Filename,
+		"File type":                         metrics.Basic.FileType,
+		"Encoding":                          metrics.Basic.Encoding,
+		"Total Sequences":                   float64(metrics.Basic.TotalSequences),
+		"Total Bases":                       metrics.Basic.TotalBases,
+		"Sequences flagged as poor quality": float64(metrics.Basic.SequencesFlaggedPoorQuality),
+		"Sequence length":                   metrics.Basic.SequenceLength,
+		"%GC":                               metrics.Basic.GCPercent,
+		"total_deduplicated_percentage":     metrics.Duplication.TotalDeduplicatedPercentage,
+		"avg_sequence_length":               average,
+		"median_sequence_length":            median,
+	}
+	for _, module := range metrics.Modules {
+		fields[moduleKey(module.Name)] = module.Status
+	}
+	return fields
+}
+
+// lengthStatistics returns the average and median read length of a length distribution.
+// Grouped lengths such as 35-39 count as their middle, like MultiQC does.
+func lengthStatistics(distribution []types.Count) (float64, float64) {
+	type bin struct {
+		length float64
+		count  float64
+	}
+	bins := make([]bin, 0, len(distribution))
+	total, sum := 0.0, 0.0
+	for _, row := range distribution {
+		length, ok := parseLength(row.Value)
+		if !ok || !row.Count.Valid() {
+			continue
+		}
+		count := float64(row.Count)
+		bins = append(bins, bin{length, count})
+		total += count
+		sum += length * count
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	sort.Slice(bins, func(i, j int) bool { return bins[i].length < bins[j].length })
+	median, seen := 0.0, 0.0
+	for _, b := range bins {
+		seen += b.count
+		if seen >= total/2 {
+			median = b.length
+			break
+		}
+	}
+	return sum / total, median
+}
+
+// parseLength reads a length or the middle of a range of lengths.
+func parseLength(value string) (float64, bool) {
+	from, to, isRange := strings.Cut(value, "-")
+	start, err := strconv.ParseFloat(from, 64)
+	if err != nil {
+		return 0, false
+	}
+	if !isRange {
+		return start, true
+	}
+	end, err := strconv.ParseFloat(to, 64)
+	if err != nil {
+		return 0, false
+	}
+	return (start + end) / 2, true
+}
+
+// moduleKey converts a module name into the key used by MultiQC, e.g. per_base_sequence_quality.
+func moduleKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), " ", "_")
+}
+
+// format writes a value the way MultiQC does in its TSV files.
+func format(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case types.Float:
+		return format(float64(v))
+	case float64:
+		if math.IsNaN(v) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// sortedSamples returns a copy of samples sorted by sample name.
+func sortedSamples(samples []Sample) []Sample {
+	sorted := append([]Sample(nil), samples...)
+	sort.SliceStable(sorted, func(i, j int) bool { return SampleName(sorted[i].Source) < SampleName(sorted[j].Source) })
+	return sorted
+}
@@ -0,0 +1,209 @@
+package qc_cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/checksum"
+)
+
+// CACHE_VERSION is part of every key. It must be bumped when the layout of an entry or the reports generated for a file change.
+const CACHE_VERSION = "2"
+
+// Layout of an entry, <root>/<key[:2]>/<key>.
+const (
+	ENTRY_FILE = "entry.json"
+	FILES_DIR  = "files"
+)
+
+// Cache stores the per-file QC results under a key derived from the input, the FastQC version and its parameters.
+// Entries are immutable: they are written to a temporary directory and renamed, so concurrent analyses never see a partial entry.
+//
+// By default inputs are identified by their name, size and modification time: a lookup must not read files of tens
+// of gigabytes. The checksums computed while FastQC read the file are part of the cached results.
+type Cache struct {
+	root     string
+	fullHash bool
+}
+
+// New returns the cache stored in root. With fullHash, inputs are identified by their SHA-256 instead of their
+// modification time, which survives copies that reset the time but reads each input once more on a lookup.
+func New(root string, fullHash bool) *Cache {
+	return &Cache{root: root, fullHash: fullHash}
+}
+
+// Fingerprint identifies the content of an input file.
+// The name is kept because the reports are named after the file, see reportStem.
+type Fingerprint struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+}
+
+// Fingerprint identifies the file at path, by its SHA-256 when the cache uses full hashes and by its modification time otherwise.
+func (c *Cache) Fingerprint(path string) (Fingerprint, error) {
+	if c.fullHash {
+		digests, err := hashFile(path)
+		if err != nil {
+			return Fingerprint{}, err
+		}
+		return Fingerprint{Name: filepath.Base(path), Size: digests.Size, SHA256: digests.SHA256}, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	return Fingerprint{Name: filepath.Base(path), Size: info.Size(), ModTime: info.ModTime().UnixNano()}, nil
+}
+
+// Key returns the key of the results of FastQC toolVersion run with parameters on the input identified by fingerprint.
+func Key(fingerprint Fingerprint, toolVersion string, parameters []string) string {
+	content, _ := json.Marshal(struct {
+		Version     string      `json:"version"`
+		Input       Fingerprint `json:"input"`
+		ToolVersion string      `json:"tool_version"`
+		Parameters  []string    `json:"parameters"`
+	}{CACHE_VERSION, fingerprint, toolVersion, parameters})
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// hashFile returns the digests of the file at path.
+func hashFile(path string) (checksum.Digests, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return checksum.Digests{}, err
+	}
+	defer file.Close()
+	hasher := checksum.NewHasher()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return checksum.Digests{}, err
+	}
+	return hasher.Digests(), nil
+}
+
+// entry is the content of ENTRY_FILE.
+type entry struct {
+	Report types.FileReport `json:"report"`
+	// Paths are the reports of the file, relative to the output directory and to FILES_DIR.
+	Paths []string `json:"paths"`
+}
+
+// dir returns the directory of the entry key.
+func (c *Cache) dir(key string) string {
+	return filepath.Join(c.root, key[:2], key)
+}
+
+// Load copies the reports of the entry key to outputDir and returns its file report, flagged as cached.
+// It returns false when there is no such entry.
+func (c *Cache) Load(key string, outputDir string) (types.FileReport, bool, error) {
+	dir := c.dir(key)
+	content, err := os.ReadFile(filepath.Join(dir, ENTRY_FILE))
+	if errors.Is(err, fs.ErrNotExist) {
+		return types.FileReport{}, false, nil
+	}
+	if err != nil {
+		return types.FileReport{}, false, err
+	}
+	var cached entry
+	if err := json.Unmarshal(content, &cached); err != nil {
+		return types.FileReport{}, false, err
+	}
+	for _, path := range cached.Paths {
+		if err := link(filepath.Join(dir, FILES_DIR, path), filepath.Join(outputDir, path)); err != nil {
+			return types.FileReport{}, false, err
+		}
+	}
+	cached.Report.Cached = true
+	return cached.Report, true, nil
+}
+
+// Store records report and its reports found at paths, relative to outputDir, as the entry key.
+// An existing entry is kept, it holds the same results.
+func (c *Cache) Store(key string, outputDir string, paths []string, report types.FileReport) error {
+	parent := filepath.Dir(c.dir(key))
+	if err := os.MkdirAll(parent, os.ModePerm); err != nil {
+		return err
+	}
+	tempDir, err := os.MkdirTemp(parent, "."+key+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, path := range paths {
+		if err := link(filepath.Join(outputDir, path), filepath.Join(tempDir, FILES_DIR, path)); err != nil {
+			return err
+		}
+	}
+	report.Cached = false
+	content, err := json.Marshal(entry{Report: report, Paths: paths})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, ENTRY_FILE), content, 0o644); err != nil {
+		return err
+	}
+
+	err = os.Rename(tempDir, c.dir(key))
+	if err != nil {
+		if _, statErr := os.Stat(filepath.Join(c.dir(key), ENTRY_FILE)); statErr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// link recreates the file or directory tree src at dst, hard linking the files when both are on the same file system
+// and copying them otherwise. The reports are never modified once written, so sharing them is safe.
+func link(src string, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return err
+		}
+		if os.Link(path, target) == nil {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+// copyFile copies the regular file src to dst.
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
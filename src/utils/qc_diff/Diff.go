@@ -0,0 +1,185 @@
+package qc_diff
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"math"
+	"regexp"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// Tolerance is the change of a metric considered as noise between two sequencing runs of the same library.
+// A change is significant when it exceeds Absolute, or Relative times the before value. A zero bound is ignored.
+type Tolerance struct {
+	Absolute float64
+	Relative float64
+}
+
+// Metric is a scalar metric compared between two reports.
+type Metric struct {
+	Name      string
+	Value     func(types.GeneralStats) float64
+	Tolerance Tolerance
+}
+
+// Metrics are the metrics compared between two reports.
+var Metrics = []Metric{
+	{"total_sequences", func(s types.GeneralStats) float64 { return float64(s.TotalSequences) }, Tolerance{Relative: 0.2}},
+	{"gc_percent", func(s types.GeneralStats) float64 { return s.GCPercent }, Tolerance{Absolute: 2}},
+	{"mean_quality", func(s types.GeneralStats) float64 { return s.MeanQuality }, Tolerance{Absolute: 1}},
+	{"q30_fraction", func(s types.GeneralStats) float64 { return s.Q30Fraction }, Tolerance{Absolute: 0.05}},
+	{"percent_duplicates", func(s types.GeneralStats) float64 { return s.PercentDuplicates }, Tolerance{Absolute: 10}},
+	{"max_adapter_percent", func(s types.GeneralStats) float64 { return s.MaxAdapterPercent }, Tolerance{Absolute: 5}},
+	{"poor_quality", func(s types.GeneralStats) float64 { return float64(s.PoorQuality) }, Tolerance{Relative: 0.5}},
+}
+
+// Compare returns what changed from before to after, labelled with the names of the reports.
+//
+// Files are paired by source first. The remaining files are paired by read, e.g. R1 or I1, when a single file of each
+// report holds that read, so that a resequenced library with new file names, e.g. another flow cell, is still compared.
+// The leftovers are reported as removed or added rather than compared with an unrelated file.
+func Compare(beforeName string, before types.Report, afterName string, after types.Report) types.Diff {
+	diff := types.Diff{Before: beforeName, After: afterName, Files: []types.FileDiff{}}
+
+	afterBySource := map[string]int{}
+	for i, file := range after.Files {
+		afterBySource[file.Source] = i
+	}
+	paired := make([]bool, len(after.Files))
+	var unmatched []types.FileReport
+	for _, file := range before.Files {
+		if i, ok := afterBySource[file.Source]; ok && !paired[i] {
+			paired[i] = true
+			diff.Files = append(diff.Files, compareFile(file, after.Files[i]))
+			continue
+		}
+		unmatched = append(unmatched, file)
+	}
+
+	// Count the remaining files of each read, a read held by several files is ambiguous.
+	beforeByRead := map[string][]types.FileReport{}
+	for _, file := range unmatched {
+		if read := readOf(file.Source); read != "" {
+			beforeByRead[read] = append(beforeByRead[read], file)
+		}
+	}
+	afterReads := map[string]int{}
+	for i, file := range after.Files {
+		if !paired[i] {
+			afterReads[readOf(file.Source)]++
+		}
+	}
+
+	pairedBefore := map[string]bool{}
+	for i, file := range after.Files {
+		if paired[i] {
+			continue
+		}
+		read := readOf(file.Source)
+		if read != "" && afterReads[read] == 1 && len(beforeByRead[read]) == 1 {
+			match := beforeByRead[read][0]
+			pairedBefore[match.Source] = true
+			diff.Files = append(diff.Files, compareFile(match, file))
+			continue
+		}
+		diff.Added = append(diff.Added, file.Source)
+	}
+	for _, file := range unmatched {
+		if !pairedBefore[file.Source] {
+			diff.Removed = append(diff.Removed, file.Source)
+		}
+	}
+	return diff
+}
+
+// readPattern matches the read of an Illumina FASTQ file name, e.g. R1 in sample_S1_L001_R1_001.fastq.gz.
+var readPattern = regexp.MustCompile(`[_.]([RI][1-4])(?:_\d+)?\.(?:fastq|fq)(?:\.gz)?$`)
+
+// readOf returns the read of a FASTQ file name, or an empty string when it has none.
+func readOf(source string) string {
+	match := readPattern.FindStringSubmatch(source)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// compareFile compares the metrics of two files.
+func compareFile(before types.FileReport, after types.FileReport) types.FileDiff {
+	diff := types.FileDiff{
+		Before:                 before.Source,
+		After:                  after.Source,
+		Modules:                []types.ModuleChange{},
+		OverrepresentedRemoved: []types.Overrepresented{},
+		OverrepresentedAdded:   []types.Overrepresented{},
+	}
+
+	// Modules are listed in the order FastQC runs them, those only run on the after file come last.
+	seen := map[string]bool{}
+	for _, modules := range [][]types.ModuleStatus{before.Metrics.Modules, after.Metrics.Modules} {
+		for _, module := range modules {
+			if seen[module.Name] {
+				continue
+			}
+			seen[module.Name] = true
+			beforeStatus, afterStatus := before.Metrics.Status(module.Name), after.Metrics.Status(module.Name)
+			if beforeStatus != afterStatus {
+				diff.Modules = append(diff.Modules, types.ModuleChange{Module: module.Name, Before: beforeStatus, After: afterStatus})
+			}
+		}
+	}
+
+	beforeStats, afterStats := before.Metrics.Summary(), after.Metrics.Summary()
+	for _, metric := range Metrics {
+		delta := types.MetricDelta{Metric: metric.Name, Before: metric.Value(beforeStats), After: metric.Value(afterStats)}
+		delta.Delta = delta.After - delta.Before
+		delta.Significant = metric.Tolerance.exceeded(delta.Before, delta.Delta)
+		diff.Metrics = append(diff.Metrics, delta)
+	}
+
+	diff.OverrepresentedRemoved = append(diff.OverrepresentedRemoved, missing(before.Metrics.Overrepresented, after.Metrics.Overrepresented)...)
+	diff.OverrepresentedAdded = append(diff.OverrepresentedAdded, missing(after.Metrics.Overrepresented, before.Metrics.Overrepresented)...)
+	return diff
+}
+
+// exceeded reports whether delta, the change of a metric from before, is beyond the tolerance.
+func (t Tolerance) exceeded(before float64, delta float64) bool {
+	if t.Absolute > 0 && math.Abs(delta) > t.Absolute {
+		return true
+	}
+	if t.Relative > 0 {
+		if before == 0 {
+			return delta != 0
+		}
+		return math.Abs(delta/before) > t.Relative
+	}
+	return false
+}
+
+// missing returns the sequences of from that are not in other.
+func missing(from []types.Overrepresented, other []types.Overrepresented) []types.Overrepresented {
+	present := map[string]bool{}
+	for _, sequence := range other {
+		present[sequence.Sequence] = true
+	}
+	var sequences []types.Overrepresented
+	for _, sequence := range from {
+		if !present[sequence.Sequence] {
+			sequences = append(sequences, sequence)
+		}
+	}
+	return sequences
+}
+
+//go:embed diff.html
+var page string
+
+// pageTemplate renders the diff. The page has no external resource so that it can be sent by mail.
+var pageTemplate = template.Must(template.New("diff").Parse(page))
+
+// WriteHTML renders diff as a standalone HTML page to w.
+func WriteHTML(w io.Writer, diff types.Diff) error {
+	return pageTemplate.Execute(w, diff)
+}
@@ -0,0 +1,176 @@
+package qc_store
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// Names of the tables tracking the migrations of the plugin.
+// They differ from the bun defaults because the results database is shared with the other plugins.
+const (
+	MIGRATIONS_TABLE      = "fastqc_migrations"
+	MIGRATION_LOCKS_TABLE = "fastqc_migration_locks"
+)
+
+// Migrations creates and evolves the QC tables. New migrations are appended, applied ones must not change.
+var Migrations = migrate.NewMigrations()
+
+func init() {
+	Migrations.Add(migrate.Migration{
+		Name:    "20241101000000",
+		Comment: "create_qc_tables",
+		Up:      execAll(createQCTables),
+		Down:    execAll(dropQCTables),
+	})
+}
+
+// The DDL of applied migrations is frozen, it must not follow later changes of the types.
+var (
+	createQCTables = []string{
+		`CREATE TABLE IF NOT EXISTS "qc_run" (
+			"id" uuid NOT NULL,
+			"analysis_id" uuid NOT NULL,
+			"result_id" uuid NOT NULL,
+			"organization_id" uuid NOT NULL,
+			"project_id" uuid,
+			"sample" VARCHAR NOT NULL,
+			"fastqc_version" VARCHAR,
+			"created_on" TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+			PRIMARY KEY ("id")
+		)`,
+		`CREATE TABLE IF NOT EXISTS "qc_file" (
+			"id" uuid NOT NULL,
+			"run_id" uuid NOT NULL,
+			"source" VARCHAR NOT NULL,
+			"size" BIGINT,
+			"sha256" VARCHAR,
+			"total_reads" BIGINT,
+			"gc_percent" DOUBLE PRECISION,
+			"mean_quality" DOUBLE PRECISION,
+			"q30_fraction" DOUBLE PRECISION,
+			"duplication_percent" DOUBLE PRECISION,
+			"adapter_percent" DOUBLE PRECISION,
+			"status" VARCHAR NOT NULL,
+			PRIMARY KEY ("id"),
+			FOREIGN KEY ("run_id") REFERENCES "qc_run" ("id") ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS "qc_module_status" (
+			"file_id" uuid NOT NULL,
+			"module" VARCHAR NOT NULL,
+			"status" VARCHAR NOT NULL,
+			PRIMARY KEY ("file_id", "module"),
+			FOREIGN KEY ("file_id") REFERENCES "qc_file" ("id") ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS "qc_run_analysis_id_idx" ON "qc_run" ("analysis_id")`,
+		`CREATE INDEX IF NOT EXISTS "qc_run_organization_id_created_on_idx" ON "qc_run" ("organization_id", "created_on")`,
+		`CREATE INDEX IF NOT EXISTS "qc_run_project_id_created_on_idx" ON "qc_run" ("project_id", "created_on")`,
+		`CREATE INDEX IF NOT EXISTS "qc_file_run_id_idx" ON "qc_file" ("run_id")`,
+	}
+	dropQCTables = []string{
+		`DROP TABLE IF EXISTS "qc_module_status"`,
+		`DROP TABLE IF EXISTS "qc_file"`,
+		`DROP TABLE IF EXISTS "qc_run"`,
+	}
+)
+
+// execAll returns a migration step running statements in a single transaction.
+func execAll(statements []string) migrate.MigrationFunc {
+	return func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, statement := range statements {
+				if _, err := tx.ExecContext(ctx, statement); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// Migrate applies the pending migrations. Replicas starting together are serialized by the migration lock.
+func Migrate(ctx context.Context, db *bun.DB) error {
+	migrator := migrate.NewMigrator(db, Migrations, migrate.WithTableName(MIGRATIONS_TABLE), migrate.WithLocksTableName(MIGRATION_LOCKS_TABLE))
+	if err := migrator.Init(ctx); err != nil {
+		return err
+	}
+	if err := migrator.Lock(ctx); err != nil {
+		return err
+	}
+	defer migrator.Unlock(ctx)
+
+	_, err := migrator.Migrate(ctx)
+	return err
+}
+
+// NewRun builds the QC records of report. The caller sets the analysis, result, organization and sample of the run.
+func NewRun(report types.Report) types.QCRun {
+	run := types.QCRun{Id: uuid.New()}
+	for _, file := range report.Files {
+		if run.FastQCVersion == "" {
+			run.FastQCVersion = file.Metrics.Version
+		}
+		stats := file.Metrics.Summary()
+		qcFile := types.QCFile{
+			Id:                 uuid.New(),
+			RunId:              run.Id,
+			Source:             file.Source,
+			Size:               file.Size,
+			SHA256:             file.SHA256,
+			TotalReads:         stats.TotalSequences,
+			GCPercent:          stats.GCPercent,
+			MeanQuality:        stats.MeanQuality,
+			Q30Fraction:        stats.Q30Fraction,
+			DuplicationPercent: stats.PercentDuplicates,
+			AdapterPercent:     stats.MaxAdapterPercent,
+			Status:             file.Metrics.OverallStatus(),
+		}
+		for _, module := range file.Metrics.Modules {
+			qcFile.Modules = append(qcFile.Modules, types.QCModuleStatus{FileId: qcFile.Id, Module: module.Name, Status: module.Status})
+		}
+		run.Files = append(run.Files, qcFile)
+	}
+	return run
+}
+
+// Insert stores run, its files and their module statuses using db, which is usually the transaction inserting the result.
+func Insert(ctx context.Context, db bun.IDB, run types.QCRun) error {
+	if _, err := db.NewInsert().Model(&run).Exec(ctx); err != nil {
+		return err
+	}
+	if len(run.Files) == 0 {
+		return nil
+	}
+	if _, err := db.NewInsert().Model(&run.Files).Exec(ctx); err != nil {
+		return err
+	}
+	var modules []types.QCModuleStatus
+	for _, file := range run.Files {
+		modules = append(modules, file.Modules...)
+	}
+	if len(modules) == 0 {
+		return nil
+	}
+	_, err := db.NewInsert().Model(&modules).Exec(ctx)
+	return err
+}
+
+// History returns the files of the most recent runs, up to limit runs, other than those of the analysis analysisId.
+// Runs are those of the project when projectId is set and those of the organization otherwise.
+func History(ctx context.Context, db bun.IDB, organizationId uuid.UUID, projectId *uuid.UUID, analysisId uuid.UUID, limit int) ([]types.QCFile, error) {
+	runs := db.NewSelect().Model((*types.QCRun)(nil)).Column("id").Where("analysis_id != ?", analysisId)
+	if projectId != nil {
+		runs = runs.Where("project_id = ?", *projectId)
+	} else {
+		runs = runs.Where("organization_id = ?", organizationId)
+	}
+	runs = runs.OrderExpr("created_on DESC").Limit(limit)
+
+	var files []types.QCFile
+	err := db.NewSelect().Model(&files).Where("run_id IN (?)", runs).Scan(ctx)
+	return files, err
+}
@@ -0,0 +1,115 @@
+package registration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+
+	plugin_db "github.com/CodeClarityCE/utility-types/plugin_db"
+	"github.com/uptrace/bun"
+	"golang.org/x/mod/semver"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// Action describes what Register did with the plugin definition.
+type Action string
+
+const (
+	INSERTED  Action = "inserted"
+	UPGRADED  Action = "upgraded"
+	UPDATED   Action = "updated"
+	UNCHANGED Action = "unchanged"
+	// OUTDATED means a newer version is already registered, e.g. while old replicas restart during a rolling deploy.
+	OUTDATED Action = "outdated"
+)
+
+// Register creates or upgrades the plugin definition and records its version.
+//
+// Replicas starting at the same time are serialized with a Postgres advisory lock held for the
+// duration of the transaction, so only the first one inserts the definition.
+// The definition is only upgraded when the version of config is newer than the registered one,
+// and its metadata is refreshed when the versions are equal.
+func Register(ctx context.Context, db *bun.DB, config plugin_db.Plugin) (Action, error) {
+	action := UNCHANGED
+	err := db.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext(?))", "plugin:"+config.Name)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewCreateTable().Model((*types.PluginVersion)(nil)).IfNotExists().Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		var existing plugin_db.Plugin
+		err = tx.NewSelect().Model(&existing).Where("name = ?", config.Name).Limit(1).Scan(ctx)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			action = INSERTED
+			if _, err := tx.NewInsert().Model(&config).Exec(ctx); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			action = Compare(existing, config)
+			if action == UPGRADED || action == UPDATED {
+				config.Id = existing.Id
+				_, err := tx.NewUpdate().Model(&config).
+					Column("version", "description", "depends_on", "config").
+					WherePK().
+					Exec(ctx)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		version := types.PluginVersion{
+			PluginName:  config.Name,
+			Version:     config.Version,
+			Description: config.Description,
+			DependsOn:   config.DependsOn,
+			Config:      config.Config,
+		}
+		_, err = tx.NewInsert().Model(&version).On("CONFLICT (plugin_name, version) DO NOTHING").Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	slog.Info("plugin registered", "plugin", config.Name, "version", config.Version, "action", action)
+	return action, nil
+}
+
+// Compare decides how the registered definition must change to match config.
+// Versions are compared as semantic versions (e.g. v0.0.2-alpha); when either is not one,
+// any difference is treated as an upgrade.
+func Compare(existing plugin_db.Plugin, config plugin_db.Plugin) Action {
+	if existing.Version != config.Version {
+		if semver.IsValid(existing.Version) && semver.IsValid(config.Version) && semver.Compare(config.Version, existing.Version) < 0 {
+			return OUTDATED
+		}
+		return UPGRADED
+	}
+	if existing.Description != config.Description ||
+		!slices.Equal(existing.DependsOn, config.DependsOn) ||
+		!sameConfig(existing.Config, config.Config) {
+		return UPDATED
+	}
+	return UNCHANGED
+}
+
+// sameConfig compares two plugin configurations, treating nil and empty as equal.
+func sameConfig(a map[string]any, b map[string]any) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
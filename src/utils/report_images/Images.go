@@ -0,0 +1,105 @@
+package report_images
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+)
+
+// maxImageSize bounds the size of an extracted image, FastQC plots are a few tens of kilobytes.
+const maxImageSize = 16 << 20
+
+// modules lists the plots drawn by FastQC in the order of its report, with the title of their module.
+var modules = []struct {
+	id    string
+	title string
+}{
+	{"per_base_quality", "Per base sequence quality"},
+	{"per_tile_quality", "Per tile sequence quality"},
+	{"per_sequence_quality", "Per sequence quality scores"},
+	{"per_base_sequence_content", "Per base sequence content"},
+	{"per_sequence_gc_content", "Per sequence GC content"},
+	{"per_base_n_content", "Per base N content"},
+	{"sequence_length_distribution", "Sequence Length Distribution"},
+	{"duplication_levels", "Sequence Duplication Levels"},
+	{"adapter_content", "Adapter Content"},
+}
+
+// Extract unpacks the Images/*.png entries of the FastQC archive <outputDir>/<stem>.zip into <outputDir>/<stem>/Images.
+// It returns the extracted plots in the order of the FastQC report, their image paths relative to outputDir.
+// Entries outside of <stem>/Images are ignored, so a crafted archive cannot write elsewhere.
+func Extract(outputDir string, stem string) ([]types.Module, error) {
+	archive, err := zip.OpenReader(filepath.Join(outputDir, stem+".zip"))
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	imagesDir := path.Join(stem, "Images")
+	extracted := map[string]string{}
+	for _, entry := range archive.File {
+		dir, name := path.Split(entry.Name)
+		if path.Clean(dir) != imagesDir || path.Ext(name) != ".png" || entry.FileInfo().IsDir() {
+			continue
+		}
+		if err := extractFile(entry, filepath.Join(outputDir, filepath.FromSlash(imagesDir), name)); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		extracted[strings.TrimSuffix(name, ".png")] = path.Join(imagesDir, name)
+	}
+
+	result := []types.Module{}
+	for _, module := range modules {
+		if image, ok := extracted[module.id]; ok {
+			result = append(result, types.Module{Id: module.id, Name: module.title, Image: image})
+			delete(extracted, module.id)
+		}
+	}
+	// Plots added by later FastQC versions are kept, named after their file.
+	for _, id := range sortedKeys(extracted) {
+		result = append(result, types.Module{Id: id, Name: id, Image: extracted[id]})
+	}
+	return result, nil
+}
+
+// extractFile writes the content of entry to destination.
+func extractFile(entry *zip.File, destination string) error {
+	if entry.UncompressedSize64 > maxImageSize {
+		return fmt.Errorf("image larger than %d bytes", maxImageSize)
+	}
+	if err := os.MkdirAll(filepath.Dir(destination), os.ModePerm); err != nil {
+		return err
+	}
+	reader, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, io.LimitReader(reader, maxImageSize)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// sortedKeys returns the keys of m in lexical order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
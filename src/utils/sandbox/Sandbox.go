@@ -0,0 +1,66 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutsideSandbox is returned when a path would resolve outside of its root.
+var ErrOutsideSandbox = errors.New("path escapes the sandbox")
+
+// Resolve joins the path elements to root and returns the resulting path with its symlinks resolved.
+//
+// Each element must be a single path component: empty elements, "." and "..", separators and NUL bytes
+// are rejected. The resolved path must exist and stay inside the resolved root, so a symlink pointing
+// outside of root is rejected as well. The errors wrap ErrOutsideSandbox, or os.ErrNotExist when the path is missing.
+func Resolve(root string, elems ...string) (string, error) {
+	for _, elem := range elems {
+		if err := checkComponent(elem); err != nil {
+			return "", err
+		}
+	}
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("resolving root: %w", err)
+	}
+	resolvedRoot, err = filepath.Abs(resolvedRoot)
+	if err != nil {
+		return "", fmt.Errorf("resolving root: %w", err)
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Join(append([]string{resolvedRoot}, elems...)...))
+	if err != nil {
+		return "", err
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !Contains(resolvedRoot, resolved) {
+		return "", fmt.Errorf("%w: %s resolves outside of %s", ErrOutsideSandbox, filepath.Join(elems...), root)
+	}
+	return resolved, nil
+}
+
+// Contains reports whether path is root or one of its descendants. Both paths must be absolute and clean.
+func Contains(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// checkComponent rejects the elements that could move the path outside of its parent.
+func checkComponent(elem string) error {
+	switch {
+	case elem == "", elem == ".", elem == "..":
+		return fmt.Errorf("%w: invalid path component %q", ErrOutsideSandbox, elem)
+	case strings.ContainsAny(elem, `/\`+"\x00"), filepath.IsAbs(elem), filepath.VolumeName(elem) != "":
+		return fmt.Errorf("%w: invalid path component %q", ErrOutsideSandbox, elem)
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	dbhelper "github.com/CodeClarityCE/utility-dbhelper/helper"
+	plugin_db "github.com/CodeClarityCE/utility-types/plugin_db"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings is the configuration of the plugin.
+//
+// It is merged from the following sources, later ones taking precedence:
+//  1. the defaults,
+//  2. config.json, holding the plugin definition, found at PLUGIN_CONFIG, in the working directory or next to the executable,
+//  3. the optional YAML file at CONFIG_FILE,
+//  4. the environment variables,
+//  5. the files named by the *_FILE environment variables (e.g. PG_DB_PASSWORD_FILE), used for Docker secrets.
+type Settings struct {
+	Plugin       plugin_db.Plugin `yaml:"-"`
+	Database     Database         `yaml:"database"`
+	AMQP         AMQP             `yaml:"amqp"`
+	DownloadPath string           `yaml:"download_path"`
+	// OutputPath is the root of the per-analysis report directories, they are created inside the sample folder when it is empty.
+	OutputPath          string        `yaml:"output_path"`
+	HTTPAddr            string        `yaml:"http_addr"`
+	ShutdownGracePeriod time.Duration `yaml:"shutdown_grace_period"`
+	LogLevel            string        `yaml:"log_level"`
+	// AuditLog is the file receiving the denied accesses, they are written to stderr when it is empty.
+	AuditLog string `yaml:"audit_log"`
+	// CachePath is the directory caching the per-file QC results, caching is disabled when it is empty.
+	CachePath string `yaml:"cache_path"`
+	// CacheFullHash identifies the cached inputs by their SHA-256 instead of their size and modification time.
+	CacheFullHash bool `yaml:"cache_full_hash"`
+	// AdminToken is the bearer token required to change the log level over HTTP, changes are refused when it is empty.
+	AdminToken Secret `yaml:"admin_token"`
+}
+
+// Database holds the Postgres connection settings.
+type Database struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password Secret `yaml:"password"`
+	Results  string `yaml:"results"`
+	Plugins  string `yaml:"plugins"`
+	// SSLMode follows the libpq semantics, see TLSConfig.
+	SSLMode     string `yaml:"sslmode"`
+	SSLRootCert string `yaml:"sslrootcert"`
+	SSLCert     string `yaml:"sslcert"`
+	SSLKey      string `yaml:"sslkey"`
+}
+
+// AMQP holds the RabbitMQ connection settings.
+type AMQP struct {
+	Protocol string `yaml:"protocol"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password Secret `yaml:"password"`
+	// The TLS settings are used with the amqps protocol.
+	CACert     string `yaml:"cacert"`
+	Cert       string `yaml:"cert"`
+	Key        string `yaml:"key"`
+	ServerName string `yaml:"server_name"`
+	// AuthMechanism is PLAIN to authenticate with the user and password, or EXTERNAL to use the client certificate.
+	AuthMechanism string `yaml:"auth_mechanism"`
+}
+
+// Secret is a string that is masked whenever it is printed, logged or marshalled.
+type Secret string
+
+const mask = "********"
+
+// Reveal returns the actual value of the secret.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return mask
+}
+
+func (s Secret) GoString() string {
+	return strconv.Quote(s.String())
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+// validSSLModes are the sslmode values accepted by Postgres.
+var validSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// defaults returns the settings used when no source provides a value.
+func defaults() Settings {
+	return Settings{
+		Database: Database{
+			SSLMode: "disable",
+			Results: dbhelper.Config.Database.Results,
+			Plugins: dbhelper.Config.Database.Plugins,
+		},
+		AMQP: AMQP{
+			Protocol:      "amqp",
+			Host:          "localhost",
+			Port:          "5672",
+			User:          "guest",
+			Password:      "guest",
+			AuthMechanism: "PLAIN",
+		},
+		HTTPAddr:            ":8080",
+		ShutdownGracePeriod: 5 * time.Minute,
+		LogLevel:            "info",
+	}
+}
+
+// Load merges every configuration source.
+// It returns all the problems encountered while reading them at once; use Validate to check the result.
+func Load() (Settings, error) {
+	settings := defaults()
+	var problems []error
+
+	if err := settings.loadPluginDefinition(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("CONFIG_FILE: %w", err))
+		} else if err := yaml.Unmarshal(content, &settings); err != nil {
+			problems = append(problems, fmt.Errorf("CONFIG_FILE %s: %w", path, err))
+		}
+	}
+
+	problems = append(problems, settings.loadEnvironment()...)
+
+	return settings, errors.Join(problems...)
+}
+
+// loadPluginDefinition decodes config.json into the plugin definition.
+func (s *Settings) loadPluginDefinition() error {
+	path := os.Getenv("PLUGIN_CONFIG")
+	if path == "" {
+		path = "config.json"
+		// Fall back to the file shipped next to the executable when started from another directory.
+		if _, err := os.Stat(path); err != nil {
+			if executable, err := os.Executable(); err == nil {
+				path = filepath.Join(filepath.Dir(executable), "config.json")
+			}
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("plugin definition: %w", err)
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(&s.Plugin); err != nil {
+		return fmt.Errorf("plugin definition %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnvironment overrides the settings with the environment variables and their *_FILE counterparts.
+// Empty values are ignored, like unset ones, so that e.g. PG_DB_PORT= in a compose file keeps the default.
+func (s *Settings) loadEnvironment() []error {
+	var problems []error
+	variables := []struct {
+		name   string
+		target *string
+	}{
+		{"PG_DB_HOST", &s.Database.Host},
+		{"PG_DB_PORT", &s.Database.Port},
+		{"PG_DB_USER", &s.Database.User},
+		{"PG_DB_PASSWORD", (*string)(&s.Database.Password)},
+		{"PG_DB_SSLMODE", &s.Database.SSLMode},
+		{"PG_DB_SSLROOTCERT", &s.Database.SSLRootCert},
+		{"PG_DB_SSLCERT", &s.Database.SSLCert},
+		{"PG_DB_SSLKEY", &s.Database.SSLKey},
+		{"AMQP_PROTOCOL", &s.AMQP.Protocol},
+		{"AMQP_HOST", &s.AMQP.Host},
+		{"AMQP_PORT", &s.AMQP.Port},
+		{"AMQP_USER", &s.AMQP.User},
+		{"AMQP_PASSWORD", (*string)(&s.AMQP.Password)},
+		{"AMQP_CACERT", &s.AMQP.CACert},
+		{"AMQP_CERT", &s.AMQP.Cert},
+		{"AMQP_KEY", &s.AMQP.Key},
+		{"AMQP_SERVER_NAME", &s.AMQP.ServerName},
+		{"AMQP_AUTH_MECHANISM", &s.AMQP.AuthMechanism},
+		{"DOWNLOAD_PATH", &s.DownloadPath},
+		{"OUTPUT_PATH", &s.OutputPath},
+		{"HTTP_ADDR", &s.HTTPAddr},
+		{"LOG_LEVEL", &s.LogLevel},
+		{"AUDIT_LOG", &s.AuditLog},
+		{"CACHE_PATH", &s.CachePath},
+		{"ADMIN_TOKEN", (*string)(&s.AdminToken)},
+	}
+	for _, variable := range variables {
+		value, ok, err := lookup(variable.name)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if ok && value != "" {
+			*variable.target = value
+		}
+	}
+
+	value, ok, err := lookup("SHUTDOWN_GRACE_PERIOD")
+	if err != nil {
+		problems = append(problems, err)
+	} else if ok && value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("SHUTDOWN_GRACE_PERIOD: %w", err))
+		} else {
+			s.ShutdownGracePeriod = duration
+		}
+	}
+
+	value, ok, err = lookup("CACHE_FULL_HASH")
+	if err != nil {
+		problems = append(problems, err)
+	} else if ok && value != "" {
+		fullHash, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("CACHE_FULL_HASH: %w", err))
+		} else {
+			s.CacheFullHash = fullHash
+		}
+	}
+	return problems
+}
+
+// lookup reads the environment variable name, or the file named by name_FILE.
+// Setting both is reported as an error because it is ambiguous.
+func lookup(name string) (string, bool, error) {
+	value, isSet := os.LookupEnv(name)
+	path, isFileSet := os.LookupEnv(name + "_FILE")
+	if isSet && isFileSet {
+		return "", false, fmt.Errorf("%s and %s_FILE are both set", name, name)
+	}
+	if isFileSet {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), true, nil
+	}
+	return value, isSet, nil
+}
+
+// Validate checks the settings needed to consume analyses and reports every problem at once.
+func (s Settings) Validate() error {
+	var problems []error
+	required := func(name string, value string) {
+		if value == "" {
+			problems = append(problems, fmt.Errorf("%s is not set", name))
+		}
+	}
+	port := func(name string, value string) {
+		if value == "" {
+			return
+		}
+		if number, err := strconv.Atoi(value); err != nil || number < 1 || number > 65535 {
+			problems = append(problems, fmt.Errorf("%s must be a port number, got %q", name, value))
+		}
+	}
+
+	required("plugin name", s.Plugin.Name)
+	required("plugin version", s.Plugin.Version)
+
+	required("PG_DB_HOST", s.Database.Host)
+	required("PG_DB_PORT", s.Database.Port)
+	port("PG_DB_PORT", s.Database.Port)
+	required("PG_DB_USER", s.Database.User)
+	required("PG_DB_PASSWORD", s.Database.Password.Reveal())
+	validMode := false
+	for _, mode := range validSSLModes {
+		validMode = validMode || s.Database.SSLMode == mode
+	}
+	if !validMode {
+		problems = append(problems, fmt.Errorf("PG_DB_SSLMODE must be one of %s, got %q", strings.Join(validSSLModes, ", "), s.Database.SSLMode))
+	} else if _, err := s.Database.TLSConfig(); err != nil {
+		problems = append(problems, err)
+	} else if s.Database.SSLMode == "allow" || s.Database.SSLMode == "prefer" {
+		slog.Warn("PG_DB_SSLMODE behaves as require, the connection fails when the server does not support TLS", "sslmode", s.Database.SSLMode)
+	}
+
+	if s.AMQP.Protocol != "amqp" && s.AMQP.Protocol != "amqps" {
+		problems = append(problems, fmt.Errorf("AMQP_PROTOCOL must be amqp or amqps, got %q", s.AMQP.Protocol))
+	}
+	required("AMQP_HOST", s.AMQP.Host)
+	port("AMQP_PORT", s.AMQP.Port)
+	if s.AMQP.AuthMechanism != "PLAIN" && s.AMQP.AuthMechanism != "EXTERNAL" {
+		problems = append(problems, fmt.Errorf("AMQP_AUTH_MECHANISM must be PLAIN or EXTERNAL, got %q", s.AMQP.AuthMechanism))
+	}
+	if s.AMQP.Protocol == "amqps" {
+		if _, err := s.AMQP.TLSConfig(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if s.AMQP.AuthMechanism == "EXTERNAL" && (s.AMQP.Protocol != "amqps" || s.AMQP.Cert == "") {
+		problems = append(problems, fmt.Errorf("AMQP_AUTH_MECHANISM EXTERNAL requires the amqps protocol and AMQP_CERT"))
+	}
+
+	required("DOWNLOAD_PATH", s.DownloadPath)
+	if s.DownloadPath != "" {
+		if info, err := os.Stat(s.DownloadPath); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Errorf("DOWNLOAD_PATH %s is not a directory", s.DownloadPath))
+		}
+	}
+
+	if s.OutputPath != "" {
+		if info, err := os.Stat(s.OutputPath); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Errorf("OUTPUT_PATH %s is not a directory", s.OutputPath))
+		}
+	}
+
+	if s.CachePath != "" {
+		if info, err := os.Stat(s.CachePath); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Errorf("CACHE_PATH %s is not a directory", s.CachePath))
+		}
+	}
+
+	if s.ShutdownGracePeriod < 0 {
+		problems = append(problems, fmt.Errorf("SHUTDOWN_GRACE_PERIOD must not be negative"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s.LogLevel)); err != nil {
+		problems = append(problems, fmt.Errorf("LOG_LEVEL: %w", err))
+	}
+
+	return errors.Join(problems...)
+}
+
+// Dump returns the settings as YAML with the secrets masked.
+func (s Settings) Dump() string {
+	content, err := yaml.Marshal(s)
+	if err != nil {
+		return err.Error()
+	}
+	return string(content)
+}
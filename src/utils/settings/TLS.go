@@ -0,0 +1,197 @@
+package settings
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// DSN returns the connection string of the Postgres database dbName.
+// The credentials are escaped, TLS is configured separately by ConnectorOptions.
+func (d Database) DSN(dbName string) string {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(d.User, d.Password.Reveal()),
+		Host:   net.JoinHostPort(d.Host, d.Port),
+		Path:   "/" + dbName,
+	}
+	return dsn.String()
+}
+
+// ConnectorOptions returns the pgdriver options connecting to the database dbName with the configured TLS settings.
+func (d Database) ConnectorOptions(dbName string) ([]pgdriver.Option, error) {
+	tlsConfig, err := d.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	// WithTLSConfig is applied after WithDSN so that it replaces the default TLS settings of the driver.
+	return []pgdriver.Option{
+		pgdriver.WithDSN(d.DSN(dbName)),
+		pgdriver.WithTLSConfig(tlsConfig),
+	}, nil
+}
+
+// TLSConfig builds the TLS configuration matching the libpq sslmode semantics.
+// It returns nil when sslmode is disable.
+//
+//	allow, prefer, require: the connection is encrypted but the server is not verified,
+//	                        unless a root certificate is given with require, which then acts as verify-ca.
+//	verify-ca:              the server certificate must be signed by the root certificate, which is required.
+//	verify-full:            the server certificate must also match the host name.
+//
+// pgdriver cannot fall back to an unencrypted connection, so allow and prefer behave as require:
+// the connection fails when the server does not support TLS. Settings.Validate warns about it.
+//
+// A client certificate and key are presented to the server when both are configured.
+func (d Database) TLSConfig() (*tls.Config, error) {
+	if d.SSLMode == "disable" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{ServerName: d.Host}
+	roots, err := loadRootCAs(d.SSLRootCert)
+	if err != nil {
+		return nil, fmt.Errorf("PG_DB_SSLROOTCERT: %w", err)
+	}
+	tlsConfig.RootCAs = roots
+
+	certificates, err := loadClientCertificate(d.SSLCert, d.SSLKey)
+	if err != nil {
+		return nil, fmt.Errorf("PG_DB_SSLCERT: %w", err)
+	}
+	tlsConfig.Certificates = certificates
+
+	switch d.SSLMode {
+	case "allow", "prefer", "require":
+		if d.SSLMode != "require" || roots == nil {
+			tlsConfig.InsecureSkipVerify = true
+			break
+		}
+		// See https://www.postgresql.org/docs/current/libpq-ssl.html#LIBQ-SSL-CERTIFICATES
+		// for why require with a root certificate behaves as verify-ca.
+		verifyChainOnly(tlsConfig)
+	case "verify-ca":
+		// The system roots would accept any publicly trusted certificate, whatever the host.
+		if roots == nil {
+			return nil, fmt.Errorf("sslmode verify-ca requires PG_DB_SSLROOTCERT")
+		}
+		verifyChainOnly(tlsConfig)
+	case "verify-full":
+	default:
+		return nil, fmt.Errorf("unsupported sslmode %q", d.SSLMode)
+	}
+	return tlsConfig, nil
+}
+
+// verifyChainOnly verifies the server certificate chain against the root certificates without checking the host name.
+// tls.Config has no option for this, so the default verification is replaced.
+func verifyChainOnly(tlsConfig *tls.Config) {
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("server did not present a certificate")
+		}
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, rawCert := range rawCerts {
+			cert, err := x509.ParseCertificate(rawCert)
+			if err != nil {
+				return fmt.Errorf("failed to parse server certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         tlsConfig.RootCAs,
+			Intermediates: intermediates,
+		})
+		return err
+	}
+}
+
+// URL returns the RabbitMQ connection URL with the credentials escaped.
+func (a AMQP) URL() string {
+	amqpURL := url.URL{
+		Scheme: a.Protocol,
+		User:   url.UserPassword(a.User, a.Password.Reveal()),
+		Host:   net.JoinHostPort(a.Host, a.Port),
+		Path:   "/",
+	}
+	return amqpURL.String()
+}
+
+// TLSConfig builds the TLS configuration of the RabbitMQ connection.
+// The server is always verified, against the CA certificate when one is configured or the system roots otherwise.
+func (a AMQP) TLSConfig() (*tls.Config, error) {
+	roots, err := loadRootCAs(a.CACert)
+	if err != nil {
+		return nil, fmt.Errorf("AMQP_CACERT: %w", err)
+	}
+	certificates, err := loadClientCertificate(a.Cert, a.Key)
+	if err != nil {
+		return nil, fmt.Errorf("AMQP_CERT: %w", err)
+	}
+	serverName := a.ServerName
+	if serverName == "" {
+		serverName = a.Host
+	}
+	return &tls.Config{
+		ServerName:   serverName,
+		RootCAs:      roots,
+		Certificates: certificates,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// Dial opens a connection to RabbitMQ.
+// With the amqps protocol the connection uses TLS, and the EXTERNAL mechanism authenticates
+// with the client certificate instead of the user and password.
+func (a AMQP) Dial() (*amqp.Connection, error) {
+	if a.Protocol != "amqps" {
+		return amqp.Dial(a.URL())
+	}
+	tlsConfig, err := a.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if a.AuthMechanism == "EXTERNAL" {
+		return amqp.DialTLS_ExternalAuth(a.URL(), tlsConfig)
+	}
+	return amqp.DialTLS(a.URL(), tlsConfig)
+}
+
+// loadRootCAs reads a PEM bundle of root certificates. It returns nil, meaning the system roots, when path is empty.
+func loadRootCAs(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return roots, nil
+}
+
+// loadClientCertificate reads a PEM certificate and its key. It returns nil when neither is configured.
+func loadClientCertificate(certPath string, keyPath string) ([]tls.Certificate, error) {
+	if certPath == "" && keyPath == "" {
+		return nil, nil
+	}
+	certificate, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return []tls.Certificate{certificate}, nil
+}
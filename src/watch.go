@@ -0,0 +1,253 @@
+package fastqc
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/logging"
+	"github.com/parithera/plugin-fastqc/src/utils/qc_cache"
+)
+
+// completionMarkers are written by Illumina sequencers once a run has been fully transferred.
+var completionMarkers = []string{"RTAComplete.txt", "CopyComplete.txt"}
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	// Directories are the roots under which sequencing runs land.
+	Directories []string
+	// StableFor is how long the FASTQ files of a folder must stay unchanged before it is analyzed.
+	StableFor time.Duration
+	// RequireMarker delays the analysis of a folder until a completion marker is also found in it or in one of its parents.
+	RequireMarker bool
+	// Skip reports whether a folder was already analyzed, it is called before running the QC.
+	Skip func(dir string) bool
+	// Cache holds the per-file results reused across folders, nil disables it.
+	Cache *qc_cache.Cache
+}
+
+// pendingRun tracks a folder that received FASTQ files and has not been analyzed yet.
+type pendingRun struct {
+	lastActivity time.Time
+	fingerprint  string
+}
+
+// Watch monitors the configured directories and runs ExecuteScript on every folder where FASTQ files land.
+// A folder is analyzed once its FASTQ files have not changed for StableFor and, with RequireMarker, a completion marker
+// is found in it or in one of its parents. A marker alone is not enough: bcl2fastq and BCL Convert write the FASTQ
+// files after the sequencer wrote its markers.
+//
+// Folders are found through inotify events and by scanning the directories at startup and every rescanInterval,
+// because inotify does not report the runs already present nor the writes made by other hosts on shared filesystems.
+// Folders analyzed since the start are not rediscovered by the scans until their FASTQ files change.
+// onReport is called with the folder and its output after each analysis. Watch returns when ctx is cancelled.
+func Watch(ctx context.Context, options WatchOptions, onReport func(dir string, output types.Output)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	roots := make([]string, 0, len(options.Directories))
+	for _, dir := range options.Directories {
+		root, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		if err := addRecursive(watcher, root); err != nil {
+			return err
+		}
+		roots = append(roots, root)
+	}
+
+	pending := map[string]*pendingRun{}
+	touch := func(dir string) {
+		if run, ok := pending[dir]; ok {
+			run.lastActivity = time.Now()
+			return
+		}
+		pending[dir] = &pendingRun{lastActivity: time.Now()}
+	}
+
+	// analyzed holds the fingerprint of the folders analyzed since the start, they are not rediscovered until they change.
+	analyzed := map[string]string{}
+	scan := func() {
+		for _, root := range roots {
+			for _, dir := range fastqDirectories(root) {
+				if _, ok := pending[dir]; ok {
+					continue
+				}
+				fingerprint := fastqFingerprint(dir)
+				if analyzed[dir] == fingerprint || (options.Skip != nil && options.Skip(dir)) {
+					continue
+				}
+				pending[dir] = &pendingRun{lastActivity: time.Now(), fingerprint: fingerprint}
+			}
+		}
+	}
+	scan()
+
+	ticker := time.NewTicker(pollInterval(options.StableFor))
+	defer ticker.Stop()
+	rescan := time.NewTicker(rescanInterval)
+	defer rescan.Stop()
+
+	logger := logging.FromContext(ctx)
+	logger.Info("watching for new sequencing runs", "directories", roots)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error("watcher error", "error", err)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					// A new run folder, watch it and pick up the files written before the watch was added.
+					if err := addRecursive(watcher, event.Name); err != nil {
+						logger.Error("failed to watch directory", "directory", event.Name, "error", err)
+					}
+					for _, dir := range fastqDirectories(event.Name) {
+						touch(dir)
+					}
+					continue
+				}
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				if isFastq(event.Name) {
+					touch(filepath.Dir(event.Name))
+				}
+			}
+			continue
+		case <-rescan.C:
+			scan()
+			continue
+		case <-ticker.C:
+		}
+
+		// Analyze the folders that are complete.
+		for dir, run := range pending {
+			fingerprint := fastqFingerprint(dir)
+			if fingerprint != run.fingerprint {
+				run.fingerprint = fingerprint
+				run.lastActivity = time.Now()
+			}
+			if time.Since(run.lastActivity) < options.StableFor {
+				continue
+			}
+			if options.RequireMarker && !hasCompletionMarker(dir, roots) {
+				continue
+			}
+			delete(pending, dir)
+			analyzed[dir] = run.fingerprint
+			if options.Skip != nil && options.Skip(dir) {
+				continue
+			}
+			runCtx := logging.With(ctx, "directory", dir)
+			logging.FromContext(runCtx).Info("running qc on sequencing run")
+			onReport(dir, ExecuteScript(runCtx, dir, filepath.Join(dir, "fastqc"), options.Cache))
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// rescanInterval is how often the watched directories are scanned for folders missed by inotify.
+const rescanInterval = time.Minute
+
+// pollInterval returns how often pending folders are checked for completion.
+func pollInterval(stableFor time.Duration) time.Duration {
+	interval := stableFor / 4
+	if interval < time.Second {
+		return time.Second
+	}
+	if interval > 30*time.Second {
+		return 30 * time.Second
+	}
+	return interval
+}
+
+// addRecursive watches dir and all of its subdirectories.
+func addRecursive(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// fastqDirectories returns the folders under dir that contain FASTQ files.
+func fastqDirectories(dir string) []string {
+	found := map[string]bool{}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && isFastq(path) {
+			found[filepath.Dir(path)] = true
+		}
+		return nil
+	})
+	dirs := make([]string, 0, len(found))
+	for dir := range found {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// fastqFingerprint summarizes the name, size and modification time of the FASTQ files in dir.
+func fastqFingerprint(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.fastq.gz"))
+	var builder strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		builder.WriteString(file)
+		builder.WriteString(info.ModTime().String())
+		builder.WriteString(strconv.FormatInt(info.Size(), 10))
+	}
+	return builder.String()
+}
+
+// hasCompletionMarker reports whether dir, or one of its parents up to a watched root, holds a completion marker.
+func hasCompletionMarker(dir string, roots []string) bool {
+	for {
+		for _, marker := range completionMarkers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return true
+			}
+		}
+		for _, root := range roots {
+			if dir == root {
+				return false
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// isFastq reports whether path is a file analyzed by ExecuteScript.
+func isFastq(path string) bool {
+	return strings.HasSuffix(path, ".fastq.gz")
+}
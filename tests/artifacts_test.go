@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parithera/plugin-fastqc/src/utils/artifacts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectArtifacts(t *testing.T) {
+	root := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "sample_R1_fastqc.html"), []byte("<html></html>"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "sample_R1_fastqc.zip"), []byte("PK"), 0o644))
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "sample_R1_fastqc", "Images"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "sample_R1_fastqc", "Images", "per_base_quality.png"), []byte{0x89, 'P', 'N', 'G'}, 0o644))
+
+	registry, err := artifacts.Collect(root, func(path string) string { return "sample_R1.fastq.gz" })
+	assert.Nil(t, err)
+	assert.Len(t, registry, 3)
+
+	assert.Equal(t, "sample_R1_fastqc.html", registry[0].Path)
+	assert.Equal(t, "html", registry[0].Kind)
+	assert.Equal(t, "text/html; charset=utf-8", registry[0].MimeType)
+	assert.Equal(t, int64(13), registry[0].Size)
+	assert.Equal(t, "sample_R1.fastq.gz", registry[0].Source)
+
+	assert.Equal(t, "sample_R1_fastqc.zip", registry[1].Path)
+	assert.Equal(t, "application/zip", registry[1].MimeType)
+
+	assert.Equal(t, "sample_R1_fastqc/Images/per_base_quality.png", registry[2].Path)
+	assert.Equal(t, "png", registry[2].Kind)
+	assert.Equal(t, "image/png", registry[2].MimeType)
+	assert.Len(t, registry[2].SHA256, 64)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/certificate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteCertificate(t *testing.T) {
+	metrics := readFastQCData(t)
+	var output bytes.Buffer
+	err := certificate.Write(&output, certificate.Certificate{
+		Project:     "run_42",
+		Analysis:    "a938bd03-aca3-4cbf-9a5c-9a536e97add4",
+		GeneratedAt: time.Date(2024, 10, 16, 15, 12, 0, 0, time.UTC),
+		Parameters:  []string{"-t", "1"},
+		Files: []types.FileReport{
+			{Source: "sample_R1.fastq.gz", Size: 1024, SHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", Metrics: metrics},
+			{Source: "sample_with_a_very_long_name_that_does_not_fit_in_the_column_R2.fastq.gz", Metrics: metrics},
+		},
+	})
+	assert.Nil(t, err)
+	assert.True(t, bytes.HasPrefix(output.Bytes(), []byte("%PDF-")))
+
+	assert.Equal(t, types.MODULE_FAIL, metrics.OverallStatus())
+	assert.Equal(t, types.MODULE_PASS, types.QCMetrics{Modules: []types.ModuleStatus{{Name: "Basic Statistics", Status: types.MODULE_PASS}}}.OverallStatus())
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/charts"
+	"github.com/parithera/plugin-fastqc/src/utils/fastqc_data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFastQCData(t *testing.T) {
+	metrics := readFastQCData(t)
+
+	assert.Equal(t, "0.12.1", metrics.Version)
+	assert.Equal(t, "sample_R1.fastq.gz", metrics.Basic.Filename)
+	assert.Equal(t, int64(250000), metrics.Basic.TotalSequences)
+	assert.Equal(t, types.Float(48), metrics.Basic.GCPercent)
+	assert.Equal(t, types.MODULE_WARN, metrics.Status(fastqc_data.PER_BASE_QUALITY))
+	assert.Equal(t, types.MODULE_FAIL, metrics.Status("Per base sequence content"))
+	assert.Len(t, metrics.Modules, 11)
+
+	assert.Equal(t, "1", metrics.PerBaseQuality[0].Base)
+	// FastQC writes NaN for the positions without data.
+	last := metrics.PerBaseQuality[len(metrics.PerBaseQuality)-1]
+	assert.Equal(t, "152", last.Base)
+	assert.False(t, last.Mean.Valid())
+	assert.False(t, last.Percentile90.Valid())
+	assert.Len(t, metrics.PerSequenceGC, 101)
+	assert.Equal(t, types.Float(85.31), metrics.Duplication.TotalDeduplicatedPercentage)
+	assert.Equal(t, ">10k+", metrics.Duplication.Levels[len(metrics.Duplication.Levels)-1].Level)
+	assert.Len(t, metrics.AdapterContent.Series, 5)
+	assert.Len(t, metrics.AdapterContent.Series[0].Values, len(metrics.AdapterContent.Positions))
+	assert.Equal(t, int64(1250), metrics.Overrepresented[0].Count)
+
+	_, err := fastqc_data.Parse(bytes.NewBufferString("not a report"))
+	assert.NotNil(t, err)
+}
+
+func TestRenderCharts(t *testing.T) {
+	figures := charts.Render(readFastQCData(t))
+	assert.Len(t, figures, 5)
+
+	for _, figure := range figures {
+		t.Run(figure.Id, func(t *testing.T) {
+			svg := figure.Figure.SVG()
+			assert.True(t, bytes.HasPrefix(svg, []byte("<svg")))
+			assert.Contains(t, string(svg), "<polyline")
+
+			content, err := figure.Figure.PNG()
+			assert.Nil(t, err)
+			image, err := png.Decode(bytes.NewReader(content))
+			assert.Nil(t, err)
+			assert.Equal(t, charts.Width, image.Bounds().Dx())
+			assert.Equal(t, charts.Height, image.Bounds().Dy())
+		})
+	}
+}
+
+func TestFastQCDataMissingValues(t *testing.T) {
+	metrics := readFastQCData(t)
+
+	// encoding/json rejects NaN, the positions without data are encoded as null.
+	data, err := json.Marshal(types.FileReport{Source: "sample_R1.fastq.gz", Metrics: metrics})
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"base":"152","mean":null`)
+
+	var decoded types.FileReport
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.False(t, decoded.Metrics.PerBaseQuality[len(decoded.Metrics.PerBaseQuality)-1].Median.Valid())
+	assert.Equal(t, metrics.PerBaseQuality[0], decoded.Metrics.PerBaseQuality[0])
+
+	summary := metrics.Summary()
+	assert.False(t, math.IsNaN(summary.MeanQuality))
+
+	figure := charts.PerBaseQuality(metrics.PerBaseQuality)
+	assert.Equal(t, 40.0, figure.YMax)
+	assert.NotContains(t, string(figure.SVG()), "NaN")
+}
+
+// readFastQCData parses the fastqc_data.txt fixture.
+func readFastQCData(t *testing.T) types.QCMetrics {
+	file, err := os.Open("fastqc_data/fastqc_data.txt")
+	assert.Nil(t, err)
+	defer file.Close()
+	metrics, err := fastqc_data.Parse(file)
+	assert.Nil(t, err)
+	return metrics
+}
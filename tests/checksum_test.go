@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/parithera/plugin-fastqc/src/utils/checksum"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksumHasher(t *testing.T) {
+	// The digests are computed on the bytes read by the consumer of the stream.
+	hasher := checksum.NewHasher()
+	consumed, err := io.ReadAll(io.TeeReader(strings.NewReader("hello\n"), hasher))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(consumed))
+
+	digests := hasher.Digests()
+	assert.Equal(t, int64(6), digests.Size)
+	assert.Equal(t, "b1946ac92492d2347c6235b4d2611184", digests.MD5)
+	assert.Equal(t, "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03", digests.SHA256)
+}
+
+func TestChecksumFindManifests(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// md5sum output, with a byte order mark and Windows line endings.
+		"md5sum.txt": "\ufeffB1946AC92492D2347C6235B4D2611184  ./a_R1.fastq.gz\r\n" +
+			"0123456789abcdef0123456789abcdef *Sample2\\b_R1.fastq.gz\r\n" +
+			"not a checksum line\r\n",
+		// BSD md5 output, read first because manifests are read in name order.
+		"MD5.txt":           "MD5 (c_R1.fastq.gz) = FEDCBA9876543210FEDCBA9876543210\nMD5 (a_R1.fastq.gz) = 00000000000000000000000000000000\n",
+		"d_R1.fastq.gz.md5": "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\n",
+		"notes.txt":         "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb  e_R1.fastq.gz\n",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	manifest, err := checksum.Find(dir)
+	assert.NoError(t, err)
+	assert.Len(t, manifest, 4)
+
+	entry, ok := manifest.Lookup("a_R1.fastq.gz")
+	assert.True(t, ok)
+	assert.Equal(t, checksum.Entry{MD5: "00000000000000000000000000000000", Manifest: "MD5.txt"}, entry)
+	entry, ok = manifest.Lookup("b_R1.fastq.gz")
+	assert.True(t, ok)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", entry.MD5)
+	entry, ok = manifest.Lookup("c_R1.fastq.gz")
+	assert.True(t, ok)
+	assert.Equal(t, "fedcba9876543210fedcba9876543210", entry.MD5)
+	entry, ok = manifest.Lookup("d_R1.fastq.gz")
+	assert.True(t, ok)
+	assert.Equal(t, "d_R1.fastq.gz.md5", entry.Manifest)
+	_, ok = manifest.Lookup("e_R1.fastq.gz")
+	assert.False(t, ok)
+}
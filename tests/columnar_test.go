@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/columnar"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnarExports(t *testing.T) {
+	files := []types.FileReport{{Source: "sample_R1.fastq.gz", Metrics: readFastQCData(t)}}
+
+	positions := columnar.PositionMetrics(files)
+	defer positions.Release()
+	assert.True(t, positions.Schema().Equal(columnar.PositionSchema))
+	assert.Greater(t, positions.NumRows(), int64(500))
+
+	var parquetFile bytes.Buffer
+	assert.Nil(t, columnar.WriteParquet(&parquetFile, positions))
+	reader, err := file.NewParquetReader(bytes.NewReader(parquetFile.Bytes()))
+	assert.Nil(t, err)
+	defer reader.Close()
+	assert.Equal(t, positions.NumRows(), reader.NumRows())
+	assert.Equal(t, columnar.SCHEMA_VERSION, *reader.MetaData().KeyValueMetadata().FindValue(columnar.SCHEMA_VERSION_KEY))
+
+	arrowReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	assert.Nil(t, err)
+	table, err := arrowReader.ReadTable(context.Background())
+	assert.Nil(t, err)
+	defer table.Release()
+	assert.Equal(t, int64(7), table.NumCols())
+
+	fileMetrics := columnar.FileMetrics(files)
+	defer fileMetrics.Release()
+	var arrowFile bytes.Buffer
+	assert.Nil(t, columnar.WriteArrow(&arrowFile, fileMetrics))
+	ipcReader, err := ipc.NewFileReader(bytes.NewReader(arrowFile.Bytes()))
+	assert.Nil(t, err)
+	defer ipcReader.Close()
+	version, _ := ipcReader.Schema().Metadata().GetValue(columnar.SCHEMA_VERSION_KEY)
+	assert.Equal(t, columnar.SCHEMA_VERSION, version)
+	record, err := ipcReader.Record(0)
+	assert.Nil(t, err)
+	assert.Equal(t, fileMetrics.NumRows(), record.NumRows())
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/parithera/plugin-fastqc/src/utils/dashboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDashboard(t *testing.T) {
+	metrics := readFastQCData(t)
+	var output bytes.Buffer
+	err := dashboard.Write(&output, "FastQC: run <1>", []dashboard.Sample{
+		{Name: "sample_R1.fastq.gz", Metrics: metrics, Report: "sample_R1_fastqc.html"},
+		{Name: "sample_R2.fastq.gz", Metrics: metrics, Report: "sample_R2_fastqc.html"},
+	})
+	assert.Nil(t, err)
+	html := output.String()
+
+	assert.Contains(t, html, "FastQC: run &lt;1&gt;")
+	assert.Contains(t, html, `<a href="sample_R2_fastqc.html">sample_R2.fastq.gz</a>`)
+	assert.Contains(t, html, `data-value="250000"`)
+	assert.Contains(t, html, `class="status fail"`)
+	assert.Equal(t, 2, bytes.Count(output.Bytes(), []byte("<svg")))
+
+	// The report must work offline.
+	assert.NotContains(t, html, "<link")
+	assert.NotContains(t, html, `src="`)
+}
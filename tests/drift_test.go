@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	exceptionManager "github.com/CodeClarityCE/utility-types/exceptions"
+	"github.com/google/uuid"
+	plugin "github.com/parithera/plugin-fastqc/src"
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/drift"
+	"github.com/parithera/plugin-fastqc/src/utils/qc_store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriftDetect(t *testing.T) {
+	var baseline []types.QCFile
+	for i := 0; i < 20; i++ {
+		baseline = append(baseline, types.QCFile{
+			TotalReads:  int64(1000000 + i*1000),
+			GCPercent:   float64(40 + i%3),
+			MeanQuality: 34 + float64(i%5)/10,
+			Q30Fraction: 0.9,
+		})
+	}
+
+	current := types.QCFile{Source: "sample_R1.fastq.gz", TotalReads: 1010000, GCPercent: 55, MeanQuality: 34.2, Q30Fraction: 0.9}
+	outliers := drift.Detect(baseline, current)
+	assert.Len(t, outliers, 1)
+	assert.Equal(t, "gc_percent", outliers[0].Metric)
+	assert.Equal(t, types.DRIFT_MAD, outliers[0].Method)
+	assert.Equal(t, 41.0, outliers[0].Center)
+	assert.Greater(t, outliers[0].Score, drift.THRESHOLD)
+	assert.Equal(t, 20, outliers[0].BaselineSize)
+
+	// A constant metric is not scored, a short history is not checked.
+	current.GCPercent, current.Q30Fraction = 41, 0.5
+	assert.Empty(t, drift.Detect(baseline, current))
+	current.GCPercent = 55
+	assert.Empty(t, drift.Detect(baseline[:drift.MIN_BASELINE-1], current))
+}
+
+func TestDriftDetectZScoreFallback(t *testing.T) {
+	var baseline []types.QCFile
+	for i := 0; i < 20; i++ {
+		// More than half of the values are equal, the MAD is zero.
+		baseline = append(baseline, types.QCFile{AdapterPercent: 0})
+	}
+	baseline[0].AdapterPercent, baseline[1].AdapterPercent = 1, 2
+
+	outliers := drift.Detect(baseline, types.QCFile{AdapterPercent: 10})
+	assert.Len(t, outliers, 1)
+	assert.Equal(t, "adapter_percent", outliers[0].Metric)
+	assert.Equal(t, types.DRIFT_ZSCORE, outliers[0].Method)
+}
+
+func TestDetectDrift(t *testing.T) {
+	metrics := readFastQCData(t)
+	var baseline []types.QCFile
+	for i := 0; i < 20; i++ {
+		file := qc_store.NewRun(types.Report{Files: []types.FileReport{{Source: "earlier.fastq.gz", Metrics: metrics}}}).Files[0]
+		file.GCPercent += float64(i%3 - 1)
+		baseline = append(baseline, file)
+	}
+
+	projectId := uuid.New()
+	analysis := codeclarity.Analysis{Id: uuid.New(), OrganizationId: uuid.New(), ProjectId: &projectId}
+	history := func(ctx context.Context, organizationId uuid.UUID, project *uuid.UUID, analysisId uuid.UUID, limit int) ([]types.QCFile, error) {
+		assert.Equal(t, analysis.OrganizationId, organizationId)
+		assert.Equal(t, &projectId, project)
+		assert.Equal(t, analysis.Id, analysisId)
+		assert.Equal(t, drift.WINDOW, limit)
+		return baseline, nil
+	}
+
+	drifted := readFastQCData(t)
+	drifted.Basic.GCPercent = 60
+	output := types.Output{
+		Result:       types.Result{Data: types.Report{Files: []types.FileReport{{Source: "sample_R1.fastq.gz", Metrics: drifted}}}},
+		AnalysisInfo: types.AnalysisInfo{Status: codeclarity.SUCCESS, Errors: []exceptionManager.Error{}},
+	}
+	assert.NoError(t, plugin.DetectDrift(context.Background(), history, analysis, &output))
+
+	assert.Equal(t, codeclarity.SUCCESS, output.AnalysisInfo.Status)
+	report := output.Result.Data.(types.Report)
+	assert.Len(t, report.Drift, 1)
+	assert.Equal(t, "gc_percent", report.Drift[0].Metric)
+	assert.Equal(t, "sample_R1.fastq.gz", report.Drift[0].Source)
+	assert.Len(t, output.AnalysisInfo.Errors, 1)
+	assert.Equal(t, types.QC_DRIFT, output.AnalysisInfo.Errors[0].Public.Type)
+	assert.Contains(t, output.AnalysisInfo.Errors[0].Public.Description, "project")
+
+	// A failed analysis has no metrics to compare, the history is not read.
+	failed := types.Output{AnalysisInfo: types.AnalysisInfo{Status: codeclarity.FAILURE}}
+	assert.NoError(t, plugin.DetectDrift(context.Background(), func(context.Context, uuid.UUID, *uuid.UUID, uuid.UUID, int) ([]types.QCFile, error) {
+		t.Fatal("history read for a failed analysis")
+		return nil, nil
+	}, analysis, &failed))
+	assert.Empty(t, failed.AnalysisInfo.Errors)
+}
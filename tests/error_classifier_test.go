@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	exceptionManager "github.com/CodeClarityCE/utility-types/exceptions"
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/error_classifier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyFastQCErrors(t *testing.T) {
+	failure := errors.New("exit status 1")
+
+	cases := map[string]struct {
+		output   string
+		err      error
+		expected exceptionManager.ERROR_TYPE
+	}{
+		"out of memory":                  {"Exception in thread \"Thread-1\" java.lang.OutOfMemoryError: Java heap space", failure, types.FASTQC_OUT_OF_MEMORY},
+		"invalid format":                 {"uk.ac.babraham.FastQC.Sequence.SequenceFormatException: ID line didn't start with '@'", failure, types.FASTQC_INVALID_FORMAT},
+		"truncated gzip":                 {"java.io.EOFException: Unexpected end of ZLIB input stream", failure, types.FASTQC_TRUNCATED_GZIP},
+		"truncated gzip without message": {"Failed to process file s_R1.fastq.gz\njava.io.EOFException\n\tat java.util.zip.GZIPInputStream.readUByte(GZIPInputStream.java:269)\n\tat uk.ac.babraham.FastQC.Sequence.FastQFile.readNext(FastQFile.java:138)", failure, types.FASTQC_TRUNCATED_GZIP},
+		"unrelated eof":                  {"java.io.EOFException\n\tat java.io.DataInputStream.readFully(DataInputStream.java:202)\n\tat uk.ac.babraham.FastQC.Modules.ModuleConfig.<init>", failure, exceptionManager.GENERIC_ERROR},
+		"java missing":                   {"Can't exec \"java\": No such file or directory at /FastQC/fastqc line 326.", failure, types.FASTQC_JAVA_MISSING},
+		"permission denied":              {"java.io.FileNotFoundException: /data/sample_R1.fastq.gz (Permission denied)", failure, types.FASTQC_PERMISSION_DENIED},
+		"disk full":                      {"java.io.IOException: No space left on device", failure, types.FASTQC_DISK_FULL},
+		"not installed":                  {"", &exec.Error{Name: "fastqc", Err: exec.ErrNotFound}, types.FASTQC_NOT_INSTALLED},
+		"unknown":                        {"something unexpected happened", failure, exceptionManager.GENERIC_ERROR},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			classified := error_classifier.Classify(c.output, c.err)
+			assert.Equal(t, c.expected, classified.Public.Type)
+			assert.Equal(t, c.expected, classified.Private.Type)
+			assert.NotEmpty(t, classified.Public.Description)
+		})
+	}
+}
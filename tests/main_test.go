@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 	"time"
 
 	dbhelper "github.com/CodeClarityCE/utility-dbhelper/helper"
 	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
 	plugin "github.com/parithera/plugin-fastqc/src"
+	"github.com/parithera/plugin-fastqc/src/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
@@ -16,6 +20,19 @@ import (
 )
 
 func TestCreateNPMv1(t *testing.T) {
+	// FASTQC_SAMPLE_DIR points to a folder of FASTQ files, it defaults to the folder mounted as /input in docker-compose.
+	sourceCodeDir := os.Getenv("FASTQC_SAMPLE_DIR")
+	if sourceCodeDir == "" {
+		sourceCodeDir = "./fastq"
+	}
+	// Without sample files the analysis succeeds without checking anything, skip instead of passing.
+	if fastqFiles, _ := filepath.Glob(filepath.Join(sourceCodeDir, "*.fastq.gz")); len(fastqFiles) == 0 {
+		t.Skipf("no .fastq.gz file in %s, set FASTQC_SAMPLE_DIR", sourceCodeDir)
+	}
+	if _, err := exec.LookPath("fastqc"); err != nil {
+		t.Skip("fastqc is not installed")
+	}
+
 	os.Setenv("PG_DB_HOST", "127.0.0.1")
 	os.Setenv("PG_DB_PORT", "5432")
 	os.Setenv("PG_DB_USER", "postgres")
@@ -26,12 +43,15 @@ func TestCreateNPMv1(t *testing.T) {
 	db_codeclarity := bun.NewDB(sqldb, pgdialect.New())
 	defer db_codeclarity.Close()
 
-	sourceCodeDir := "/Users/cedric/Documents/workspace/parithera-dev/private/20e14aae-b8ca-4fad-a351-6d747b9ab070/67e09357-aefb-44a2-a978-1c508e16eb23"
-	out := plugin.Start(sourceCodeDir, db_codeclarity)
+	out := plugin.Start(context.Background(), sourceCodeDir, filepath.Join(sourceCodeDir, "fastqc"), db_codeclarity, nil)
 
 	// Assert the expected values
 	assert.NotNil(t, out)
 	assert.Equal(t, codeclarity.SUCCESS, out.AnalysisInfo.Status)
+	report, ok := out.Result.Data.(types.Report)
+	if assert.True(t, ok, "the result holds no report") {
+		assert.NotEmpty(t, report.Files)
+	}
 
 	writeJSON(out, sourceCodeDir+"/fastqc.json")
 }
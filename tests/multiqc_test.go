@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/parithera/plugin-fastqc/src/utils/multiqc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiQCExports(t *testing.T) {
+	metrics := readFastQCData(t)
+	samples := []multiqc.Sample{
+		{Source: "sample_R2.fastq.gz", Metrics: metrics},
+		{Source: "sample_R1.fastq.gz", Metrics: metrics},
+	}
+
+	header, rows := multiqc.FastQCTable(samples)
+	assert.Equal(t, "Sample", header[0])
+	assert.Contains(t, header, "per_base_sequence_quality")
+	assert.Contains(t, header, "total_deduplicated_percentage")
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "sample_R1", rows[0][0])
+	column := func(name string) string {
+		for i, h := range header {
+			if h == name {
+				return rows[0][i]
+			}
+		}
+		return ""
+	}
+	assert.Equal(t, "250000", column("Total Sequences"))
+	assert.Equal(t, "warn", column("per_base_sequence_quality"))
+	assert.Equal(t, "fail", column("per_base_sequence_content"))
+	assert.Equal(t, "85.31", column("total_deduplicated_percentage"))
+	assert.Equal(t, "150.5", column("median_sequence_length"))
+
+	var tsv bytes.Buffer
+	assert.Nil(t, multiqc.WriteTSV(&tsv, header, rows))
+	lines := strings.Split(strings.TrimSpace(tsv.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, len(header), len(strings.Split(lines[1], "\t")))
+
+	var content bytes.Buffer
+	assert.Nil(t, multiqc.WriteJSON(&content, samples))
+	var document struct {
+		GeneralStats []map[string]map[string]float64 `json:"report_general_stats_data"`
+		RawData      map[string]map[string]any       `json:"report_saved_raw_data"`
+	}
+	assert.Nil(t, json.Unmarshal(content.Bytes(), &document))
+	assert.InDelta(t, 14.69, document.GeneralStats[0]["sample_R1"]["percent_duplicates"], 0.001)
+	assert.Equal(t, 250000.0, document.GeneralStats[0]["sample_R2"]["total_sequences"])
+	assert.Contains(t, document.RawData["multiqc_fastqc"], "sample_R1")
+}
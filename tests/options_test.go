@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	plugin_db "github.com/CodeClarityCE/utility-types/plugin_db"
+	plugin "github.com/parithera/plugin-fastqc/src"
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOptions(t *testing.T) {
+	content, err := os.ReadFile("../config.json")
+	assert.Nil(t, err)
+	var definition plugin_db.Plugin
+	assert.Nil(t, json.Unmarshal(content, &definition))
+
+	options, errs := plugin.ParseOptions(definition.Config, map[string]any{"sample": "sample_1"})
+	assert.Empty(t, errs)
+	assert.Equal(t, "sample_1", options.Sample)
+
+	cases := map[string]struct {
+		raw    any
+		fields []string
+	}{
+		"missing":         {nil, []string{"configuration"}},
+		"not an object":   {"sample_1", []string{"configuration"}},
+		"missing sample":  {map[string]any{}, []string{"configuration"}},
+		"wrong type":      {map[string]any{"sample": 42.0}, []string{"sample"}},
+		"empty sample":    {map[string]any{"sample": ""}, []string{"sample", "sample"}},
+		"path separator":  {map[string]any{"sample": "../other/samples/x"}, []string{"sample"}},
+		"unknown field":   {map[string]any{"sample": "sample_1", "other": true}, []string{"configuration"}},
+		"several invalid": {map[string]any{"sample": 42.0, "other": true}, []string{"sample", "configuration"}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, errs := plugin.ParseOptions(definition.Config, c.raw)
+			assert.Len(t, errs, len(c.fields))
+			for _, e := range errs {
+				assert.Equal(t, types.INVALID_CONFIGURATION, e.Public.Type)
+			}
+			for i, field := range c.fields {
+				if i < len(errs) {
+					assert.Contains(t, errs[i].Public.Description, field+":")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/qc_cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQCCacheKey(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sample_R1.fastq.gz")
+	assert.NoError(t, os.WriteFile(input, []byte("@read\nACGT\n+\nIIII\n"), 0o644))
+
+	cache := qc_cache.New(t.TempDir(), false)
+	fingerprint, err := cache.Fingerprint(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "sample_R1.fastq.gz", fingerprint.Name)
+	assert.Equal(t, int64(18), fingerprint.Size)
+	assert.NotZero(t, fingerprint.ModTime)
+
+	key := qc_cache.Key(fingerprint, "FastQC v0.12.1", []string{"-t", "1"})
+	assert.Equal(t, key, qc_cache.Key(fingerprint, "FastQC v0.12.1", []string{"-t", "1"}))
+	assert.NotEqual(t, key, qc_cache.Key(fingerprint, "FastQC v0.11.9", []string{"-t", "1"}))
+	assert.NotEqual(t, key, qc_cache.Key(fingerprint, "FastQC v0.12.1", []string{"-t", "2"}))
+
+	// Touching the file invalidates its entries.
+	assert.NoError(t, os.Chtimes(input, time.Now(), time.Now().Add(time.Hour)))
+	touched, err := cache.Fingerprint(input)
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, qc_cache.Key(touched, "FastQC v0.12.1", []string{"-t", "1"}))
+}
+
+func TestQCCacheFullHash(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "sample_R1.fastq.gz")
+	assert.NoError(t, os.WriteFile(input, []byte("@read\nACGT\n+\nIIII\n"), 0o644))
+
+	cache := qc_cache.New(t.TempDir(), true)
+	fingerprint, err := cache.Fingerprint(input)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(18), fingerprint.Size)
+	assert.Zero(t, fingerprint.ModTime)
+	assert.Len(t, fingerprint.SHA256, 64)
+
+	// Copies that reset the modification time keep their entries.
+	assert.NoError(t, os.Chtimes(input, time.Now(), time.Now().Add(time.Hour)))
+	touched, err := cache.Fingerprint(input)
+	assert.NoError(t, err)
+	assert.Equal(t, fingerprint, touched)
+
+	assert.NoError(t, os.WriteFile(input, []byte("@read\nACGA\n+\nIIII\n"), 0o644))
+	changed, err := cache.Fingerprint(input)
+	assert.NoError(t, err)
+	assert.NotEqual(t, fingerprint.SHA256, changed.SHA256)
+}
+
+func TestQCCacheStoreLoad(t *testing.T) {
+	cache := qc_cache.New(t.TempDir(), false)
+	key := qc_cache.Key(qc_cache.Fingerprint{Name: "sample_R1.fastq.gz", Size: 10}, "FastQC v0.12.1", nil)
+
+	report, hit, err := cache.Load(key, t.TempDir())
+	assert.NoError(t, err)
+	assert.False(t, hit)
+
+	outputDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(outputDir, "sample_R1_fastqc.html"), []byte("<html>"), 0o644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(outputDir, "sample_R1_fastqc", "charts"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(outputDir, "sample_R1_fastqc", "charts", "quality.svg"), []byte("<svg>"), 0o644))
+	stored := types.FileReport{Source: "sample_R1.fastq.gz", Size: 10, SHA256: "abc"}
+	paths := []string{"sample_R1_fastqc.html", "sample_R1_fastqc"}
+	assert.NoError(t, cache.Store(key, outputDir, paths, stored))
+	// Storing the same entry again keeps the first one.
+	assert.NoError(t, cache.Store(key, outputDir, paths, stored))
+
+	restoreDir := t.TempDir()
+	report, hit, err = cache.Load(key, restoreDir)
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.True(t, report.Cached)
+	assert.Equal(t, "abc", report.SHA256)
+	content, err := os.ReadFile(filepath.Join(restoreDir, "sample_R1_fastqc", "charts", "quality.svg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "<svg>", string(content))
+	assert.FileExists(t, filepath.Join(restoreDir, "sample_R1_fastqc.html"))
+}
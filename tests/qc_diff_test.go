@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	plugin "github.com/parithera/plugin-fastqc/src"
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/qc_diff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQCDiffCompare(t *testing.T) {
+	metrics := readFastQCData(t)
+	before := types.Report{Files: []types.FileReport{
+		{Source: "lane1_R1.fastq.gz", Metrics: metrics},
+		{Source: "shared.fastq.gz", Metrics: metrics},
+		{Source: "dropped.fastq.gz", Metrics: metrics},
+	}}
+
+	resequenced := readFastQCData(t)
+	resequenced.Basic.GCPercent += 5
+	resequenced.Basic.TotalSequences += 1000
+	resequenced.Modules = append([]types.ModuleStatus(nil), metrics.Modules...)
+	resequenced.Modules[0].Status = types.MODULE_WARN
+	resequenced.Overrepresented = []types.Overrepresented{{Sequence: "ACGTACGT", Count: 10, Percentage: 1.5, Source: "No Hit"}}
+	after := types.Report{Files: []types.FileReport{
+		{Source: "shared.fastq.gz", Metrics: metrics},
+		{Source: "lane2_R1.fastq.gz", Metrics: resequenced},
+	}}
+
+	diff := qc_diff.Compare("before.json", before, "after.json", after)
+	assert.Equal(t, []string{"dropped.fastq.gz"}, diff.Removed)
+	assert.Empty(t, diff.Added)
+	assert.Len(t, diff.Files, 2)
+
+	same := diff.Files[0]
+	assert.Equal(t, "shared.fastq.gz", same.After)
+	assert.Empty(t, same.Modules)
+	for _, metric := range same.Metrics {
+		assert.False(t, metric.Significant, metric.Metric)
+	}
+
+	// Files with new names are paired by read.
+	changed := diff.Files[1]
+	assert.Equal(t, "lane1_R1.fastq.gz", changed.Before)
+	assert.Equal(t, "lane2_R1.fastq.gz", changed.After)
+	assert.Equal(t, []types.ModuleChange{{Module: metrics.Modules[0].Name, Before: metrics.Modules[0].Status, After: types.MODULE_WARN}}, changed.Modules)
+	for _, metric := range changed.Metrics {
+		switch metric.Metric {
+		case "gc_percent":
+			assert.Equal(t, 5.0, metric.Delta)
+			assert.True(t, metric.Significant)
+		case "total_sequences":
+			assert.Equal(t, 1000.0, metric.Delta)
+			assert.False(t, metric.Significant)
+		}
+	}
+	assert.Len(t, changed.OverrepresentedAdded, 1)
+	assert.Len(t, changed.OverrepresentedRemoved, len(metrics.Overrepresented))
+
+	var html bytes.Buffer
+	assert.NoError(t, qc_diff.WriteHTML(&html, diff))
+	assert.Contains(t, html.String(), "ACGTACGT")
+	assert.Contains(t, html.String(), "Only in before.json: dropped.fastq.gz")
+}
+
+func TestQCDiffPairByRead(t *testing.T) {
+	metrics := readFastQCData(t)
+	files := func(sources ...string) types.Report {
+		report := types.Report{}
+		for _, source := range sources {
+			report.Files = append(report.Files, types.FileReport{Source: source, Metrics: metrics})
+		}
+		return report
+	}
+
+	// The list order does not matter, R2 is not compared with R1.
+	diff := qc_diff.Compare("before.json", files("S1_L001_R1_001.fastq.gz", "S1_L001_R2_001.fastq.gz"),
+		"after.json", files("S1_L002_R2_001.fastq.gz", "S1_L002_R1_001.fastq.gz"))
+	assert.Empty(t, diff.Added)
+	assert.Empty(t, diff.Removed)
+	if assert.Len(t, diff.Files, 2) {
+		assert.Equal(t, "S1_L001_R2_001.fastq.gz", diff.Files[0].Before)
+		assert.Equal(t, "S1_L002_R2_001.fastq.gz", diff.Files[0].After)
+		assert.Equal(t, "S1_L001_R1_001.fastq.gz", diff.Files[1].Before)
+		assert.Equal(t, "S1_L002_R1_001.fastq.gz", diff.Files[1].After)
+	}
+
+	// Several files holding the same read, or files without a read, are not guessed.
+	diff = qc_diff.Compare("before.json", files("L001_R1.fastq.gz", "L002_R1.fastq.gz", "sample.fastq.gz"),
+		"after.json", files("L003_R1.fastq.gz", "L004_R1.fastq.gz", "other.fastq.gz", "L003_I1.fastq.gz"))
+	assert.Empty(t, diff.Files)
+	assert.Equal(t, []string{"L003_R1.fastq.gz", "L004_R1.fastq.gz", "other.fastq.gz", "L003_I1.fastq.gz"}, diff.Added)
+	assert.Equal(t, []string{"L001_R1.fastq.gz", "L002_R1.fastq.gz", "sample.fastq.gz"}, diff.Removed)
+}
+
+func TestDecodeReport(t *testing.T) {
+	report := types.Report{Files: []types.FileReport{{Source: "sample.fastq.gz", Metrics: readFastQCData(t)}}}
+	output := types.Output{Result: types.Result{Data: report}, AnalysisInfo: types.AnalysisInfo{Status: codeclarity.SUCCESS}}
+	data, err := json.Marshal(output)
+	assert.NoError(t, err)
+
+	decoded, err := plugin.DecodeReport(data)
+	assert.NoError(t, err)
+	assert.Equal(t, report.Files[0].Metrics.Basic, decoded.Files[0].Metrics.Basic)
+
+	output.AnalysisInfo.Status = codeclarity.FAILURE
+	output.Result.Data = nil
+	data, err = json.Marshal(output)
+	assert.NoError(t, err)
+	_, err = plugin.DecodeReport(data)
+	assert.Error(t, err)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/qc_store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewQCRun(t *testing.T) {
+	metrics := readFastQCData(t)
+	run := qc_store.NewRun(types.Report{Files: []types.FileReport{
+		{Source: "sample_R1.fastq.gz", Size: 1024, SHA256: "abc", Metrics: metrics},
+	}})
+
+	assert.Equal(t, "0.12.1", run.FastQCVersion)
+	assert.Len(t, run.Files, 1)
+	file := run.Files[0]
+	assert.Equal(t, run.Id, file.RunId)
+	assert.Equal(t, int64(250000), file.TotalReads)
+	assert.Equal(t, 48.0, file.GCPercent)
+	assert.InDelta(t, 14.69, file.DuplicationPercent, 0.001)
+	assert.Greater(t, file.Q30Fraction, 0.5)
+	assert.Less(t, file.Q30Fraction, 1.0)
+	assert.Equal(t, types.MODULE_FAIL, file.Status)
+	assert.Len(t, file.Modules, len(metrics.Modules))
+	assert.Equal(t, file.Id, file.Modules[0].FileId)
+	assert.Equal(t, "Basic Statistics", file.Modules[0].Module)
+}
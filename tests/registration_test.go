@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	plugin_db "github.com/CodeClarityCE/utility-types/plugin_db"
+	"github.com/parithera/plugin-fastqc/src/utils/registration"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareRegisteredPlugin(t *testing.T) {
+	existing := plugin_db.Plugin{Name: "fastqc", Version: "v0.0.2-alpha", Description: "A plugin to run an R script", DependsOn: []string{}}
+
+	same := existing
+	same.DependsOn = nil
+	assert.Equal(t, registration.UNCHANGED, registration.Compare(existing, same))
+
+	newer := existing
+	newer.Version = "v0.0.3-alpha"
+	assert.Equal(t, registration.UPGRADED, registration.Compare(existing, newer))
+
+	older := existing
+	older.Version = "v0.0.1-alpha"
+	assert.Equal(t, registration.OUTDATED, registration.Compare(existing, older))
+
+	described := existing
+	described.Description = "Quality control of sequencing reads"
+	assert.Equal(t, registration.UPDATED, registration.Compare(existing, described))
+
+	unversioned := existing
+	unversioned.Version = "latest"
+	assert.Equal(t, registration.UPGRADED, registration.Compare(existing, unversioned))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parithera/plugin-fastqc/src/utils/report_images"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractReportImages(t *testing.T) {
+	outputDir := t.TempDir()
+	file, err := os.Create(filepath.Join(outputDir, "sample_R1_fastqc.zip"))
+	assert.Nil(t, err)
+	archive := zip.NewWriter(file)
+	for _, name := range []string{
+		"sample_R1_fastqc/fastqc_data.txt",
+		"sample_R1_fastqc/Images/adapter_content.png",
+		"sample_R1_fastqc/Images/per_base_quality.png",
+		"sample_R1_fastqc/Images/new_plot.png",
+		"sample_R1_fastqc/Images/../../escape.png",
+		"../escape.png",
+	} {
+		writer, err := archive.Create(name)
+		assert.Nil(t, err)
+		_, err = writer.Write([]byte{0x89, 'P', 'N', 'G'})
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, archive.Close())
+	assert.Nil(t, file.Close())
+
+	modules, err := report_images.Extract(outputDir, "sample_R1_fastqc")
+	assert.Nil(t, err)
+	assert.Len(t, modules, 3)
+	assert.Equal(t, "per_base_quality", modules[0].Id)
+	assert.Equal(t, "Per base sequence quality", modules[0].Name)
+	assert.Equal(t, "sample_R1_fastqc/Images/per_base_quality.png", modules[0].Image)
+	assert.Equal(t, "adapter_content", modules[1].Id)
+	assert.Equal(t, "new_plot", modules[2].Id)
+
+	assert.FileExists(t, filepath.Join(outputDir, "sample_R1_fastqc", "Images", "adapter_content.png"))
+	assert.NoFileExists(t, filepath.Join(outputDir, "escape.png"))
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(outputDir), "escape.png"))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
+	"github.com/google/uuid"
+	plugin "github.com/parithera/plugin-fastqc/src"
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/parithera/plugin-fastqc/src/utils/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveSample(t *testing.T) {
+	var records bytes.Buffer
+	audit.SetOutput(&records)
+	defer audit.SetOutput(os.Stderr)
+
+	downloadPath := t.TempDir()
+	organizationId := uuid.New()
+	otherOrganizationId := uuid.New()
+	samples := filepath.Join(downloadPath, organizationId.String(), "samples")
+	otherSamples := filepath.Join(downloadPath, otherOrganizationId.String(), "samples")
+	assert.Nil(t, os.MkdirAll(filepath.Join(samples, "sample_1"), 0o755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(otherSamples, "sample_2"), 0o755))
+	assert.Nil(t, os.Symlink(filepath.Join(otherSamples, "sample_2"), filepath.Join(samples, "escape")))
+
+	analysis := codeclarity.Analysis{Id: uuid.New(), OrganizationId: organizationId}
+	ctx := context.Background()
+
+	path, err := plugin.ResolveSample(ctx, downloadPath, organizationId, analysis, "sample_1")
+	assert.Nil(t, err)
+	expected, _ := filepath.EvalSymlinks(filepath.Join(samples, "sample_1"))
+	assert.Equal(t, expected, path)
+
+	_, err = plugin.ResolveSample(ctx, downloadPath, organizationId, analysis, "missing")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, types.SAMPLE_NOT_FOUND, err.Public.Type)
+	}
+	assert.Empty(t, records.String())
+
+	// The analysis belongs to another organization.
+	_, err = plugin.ResolveSample(ctx, downloadPath, otherOrganizationId, analysis, "sample_2")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, types.SAMPLE_ACCESS_DENIED, err.Public.Type)
+	}
+	assert.Contains(t, records.String(), "the analysis belongs to another organization")
+
+	// A symlink to the samples of another organization.
+	records.Reset()
+	_, err = plugin.ResolveSample(ctx, downloadPath, organizationId, analysis, "escape")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, types.SAMPLE_ACCESS_DENIED, err.Public.Type)
+	}
+	assert.Contains(t, records.String(), "access_denied")
+
+	for _, sample := range []string{"..", "../" + otherOrganizationId.String()} {
+		_, err = plugin.ResolveSample(ctx, downloadPath, organizationId, analysis, sample)
+		if assert.NotNil(t, err, sample) {
+			assert.Equal(t, types.SAMPLE_ACCESS_DENIED, err.Public.Type, sample)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parithera/plugin-fastqc/src/utils/sandbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxResolve(t *testing.T) {
+	root := t.TempDir()
+	other := t.TempDir()
+	samples := filepath.Join(root, "org", "samples")
+	assert.Nil(t, os.MkdirAll(filepath.Join(samples, "sample_1"), 0o755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "other_org", "samples", "x"), 0o755))
+	assert.Nil(t, os.Symlink(other, filepath.Join(samples, "escape")))
+	assert.Nil(t, os.Symlink(filepath.Join(samples, "sample_1"), filepath.Join(samples, "alias")))
+
+	resolved, err := sandbox.Resolve(root, "org", "samples", "sample_1")
+	assert.Nil(t, err)
+	expected, _ := filepath.EvalSymlinks(filepath.Join(samples, "sample_1"))
+	assert.Equal(t, expected, resolved)
+
+	// A symlink staying inside the root is allowed.
+	resolved, err = sandbox.Resolve(root, "org", "samples", "alias")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, resolved)
+
+	for _, sample := range []string{"..", ".", "", "../../other_org/samples/x", "/etc", "a\x00b"} {
+		_, err := sandbox.Resolve(root, "org", "samples", sample)
+		assert.ErrorIs(t, err, sandbox.ErrOutsideSandbox, sample)
+	}
+
+	_, err = sandbox.Resolve(root, "org", "samples", "escape")
+	assert.ErrorIs(t, err, sandbox.ErrOutsideSandbox)
+
+	_, err = sandbox.Resolve(root, "org", "samples", "missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
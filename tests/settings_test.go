@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parithera/plugin-fastqc/src/utils/settings"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingsMergeSources(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "config.yaml")
+	os.WriteFile(yamlPath, []byte("database:\n  host: yaml-host\n  port: 5433\nshutdown_grace_period: 30s\n"), 0644)
+	secretPath := filepath.Join(dir, "password")
+	os.WriteFile(secretPath, []byte("s3cret\n"), 0600)
+
+	unsetEnv(t, "PG_DB_HOST", "PG_DB_PORT", "PG_DB_USER", "PG_DB_PASSWORD", "AMQP_USER", "SHUTDOWN_GRACE_PERIOD")
+	t.Setenv("PLUGIN_CONFIG", "../config.json")
+	t.Setenv("CONFIG_FILE", yamlPath)
+	t.Setenv("PG_DB_HOST", "env-host")
+	t.Setenv("PG_DB_PASSWORD_FILE", secretPath)
+
+	loaded, err := settings.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "fastqc", loaded.Plugin.Name)
+	assert.Equal(t, "env-host", loaded.Database.Host)
+	assert.Equal(t, "5433", loaded.Database.Port)
+	assert.Equal(t, "s3cret", loaded.Database.Password.Reveal())
+	assert.Equal(t, 30*time.Second, loaded.ShutdownGracePeriod)
+	assert.Equal(t, "guest", loaded.AMQP.User)
+}
+
+func TestSettingsIgnoreEmptyVariables(t *testing.T) {
+	unsetEnv(t, "CONFIG_FILE", "AMQP_HOST_FILE", "HTTP_ADDR_FILE", "SHUTDOWN_GRACE_PERIOD_FILE")
+	t.Setenv("PLUGIN_CONFIG", "../config.json")
+	t.Setenv("AMQP_HOST", "")
+	t.Setenv("HTTP_ADDR", "")
+	t.Setenv("SHUTDOWN_GRACE_PERIOD", "")
+
+	loaded, err := settings.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost", loaded.AMQP.Host)
+	assert.Equal(t, ":8080", loaded.HTTPAddr)
+	assert.Equal(t, 5*time.Minute, loaded.ShutdownGracePeriod)
+}
+
+func TestSettingsMaskSecrets(t *testing.T) {
+	loaded := settings.Settings{Database: settings.Database{Password: "s3cret"}, AMQP: settings.AMQP{Password: "guest"}, AdminToken: "s3cret"}
+
+	encoded, err := json.Marshal(loaded)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(encoded), "s3cret")
+	assert.NotContains(t, loaded.Dump(), "s3cret")
+	assert.NotContains(t, fmt.Sprintf("%v %+v %#v", loaded, loaded, loaded), "s3cret")
+}
+
+func TestSettingsValidateReportsEveryProblem(t *testing.T) {
+	invalid := settings.Settings{
+		Database: settings.Database{Port: "not-a-port", SSLMode: "sometimes"},
+		AMQP:     settings.AMQP{Protocol: "http"},
+		LogLevel: "verbose",
+	}
+
+	err := invalid.Validate()
+	assert.Error(t, err)
+	for _, problem := range []string{"plugin name", "PG_DB_HOST", "PG_DB_PORT must be a port number", "PG_DB_PASSWORD", "PG_DB_SSLMODE", "AMQP_PROTOCOL", "DOWNLOAD_PATH", "LOG_LEVEL"} {
+		assert.Contains(t, err.Error(), problem)
+	}
+}
+
+// unsetEnv removes environment variables for the duration of the test.
+func unsetEnv(t *testing.T, names ...string) {
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			os.Unsetenv(name)
+			t.Cleanup(func() { os.Setenv(name, value) })
+		}
+	}
+}
+
+func TestSettingsEscapeCredentials(t *testing.T) {
+	database := settings.Database{Host: "db", Port: "5432", User: "plugin", Password: "p@ss/w:rd?#", SSLMode: "disable"}
+	dsn, err := url.Parse(database.DSN("codeclarity"))
+	assert.NoError(t, err)
+	password, _ := dsn.User.Password()
+	assert.Equal(t, "p@ss/w:rd?#", password)
+	assert.Equal(t, "db:5432", dsn.Host)
+	assert.Equal(t, "/codeclarity", dsn.Path)
+
+	broker := settings.AMQP{Protocol: "amqps", Host: "rabbitmq", Port: "5671", User: "plugin", Password: "p@ss/w:rd"}
+	uri, err := amqp.ParseURI(broker.URL())
+	assert.NoError(t, err)
+	assert.Equal(t, "p@ss/w:rd", uri.Password)
+	assert.Equal(t, "rabbitmq", uri.Host)
+	assert.Equal(t, "/", uri.Vhost)
+}
+
+func TestSettingsDatabaseTLSModes(t *testing.T) {
+	database := settings.Database{Host: "db", SSLMode: "disable"}
+	tlsConfig, err := database.TLSConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+
+	database.SSLMode = "require"
+	tlsConfig, err = database.TLSConfig()
+	assert.NoError(t, err)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+
+	// verify-ca must not fall back to the system roots.
+	database.SSLMode = "verify-ca"
+	_, err = database.TLSConfig()
+	assert.ErrorContains(t, err, "PG_DB_SSLROOTCERT")
+
+	database.SSLMode = "verify-full"
+	tlsConfig, err = database.TLSConfig()
+	assert.NoError(t, err)
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+	assert.Equal(t, "db", tlsConfig.ServerName)
+
+	database.SSLRootCert = filepath.Join(t.TempDir(), "missing.pem")
+	_, err = database.TLSConfig()
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	plugin "github.com/parithera/plugin-fastqc/src"
+	"github.com/parithera/plugin-fastqc/src/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// watchOnce runs Watch on root and returns the folders analyzed before timeout.
+func watchOnce(t *testing.T, options plugin.WatchOptions, timeout time.Duration, during func()) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var analyzed []string
+	done := make(chan error, 1)
+	go func() {
+		done <- plugin.Watch(ctx, options, func(dir string, output types.Output) {
+			analyzed = append(analyzed, dir)
+			cancel()
+		})
+	}()
+	if during != nil {
+		during()
+	}
+	assert.Nil(t, <-done)
+	return analyzed
+}
+
+func TestWatchScansExistingRuns(t *testing.T) {
+	root := t.TempDir()
+	run := filepath.Join(root, "run_1")
+	assert.Nil(t, os.MkdirAll(run, 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(run, "s_R1.fastq.gz"), []byte("reads"), 0o644))
+
+	// The run landed before the watch started, no inotify event is raised for it.
+	analyzed := watchOnce(t, plugin.WatchOptions{Directories: []string{root}, StableFor: 10 * time.Millisecond}, 5*time.Second, nil)
+	assert.Equal(t, []string{run}, analyzed)
+
+	// Skipped folders are not analyzed.
+	analyzed = watchOnce(t, plugin.WatchOptions{
+		Directories: []string{root},
+		StableFor:   10 * time.Millisecond,
+		Skip:        func(string) bool { return true },
+	}, 1500*time.Millisecond, nil)
+	assert.Empty(t, analyzed)
+}
+
+func TestWatchRequireMarker(t *testing.T) {
+	root := t.TempDir()
+	run := filepath.Join(root, "run_1")
+	assert.Nil(t, os.MkdirAll(run, 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(run, "s_R1.fastq.gz"), []byte("reads"), 0o644))
+
+	options := plugin.WatchOptions{Directories: []string{root}, StableFor: 10 * time.Millisecond, RequireMarker: true}
+	analyzed := watchOnce(t, options, 1500*time.Millisecond, nil)
+	assert.Empty(t, analyzed)
+
+	analyzed = watchOnce(t, options, 5*time.Second, func() {
+		time.Sleep(1500 * time.Millisecond)
+		assert.Nil(t, os.WriteFile(filepath.Join(root, "RTAComplete.txt"), nil, 0o644))
+	})
+	assert.Equal(t, []string{run}, analyzed)
+}
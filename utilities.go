@@ -4,16 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"time"
 
-	amqp_helper "github.com/CodeClarityCE/utility-amqp-helper"
-	dbhelper "github.com/CodeClarityCE/utility-dbhelper/helper"
 	types_amqp "github.com/CodeClarityCE/utility-types/amqp"
 	codeclarity "github.com/CodeClarityCE/utility-types/codeclarity_db"
 	plugin_db "github.com/CodeClarityCE/utility-types/plugin_db"
+	"github.com/parithera/plugin-fastqc/src/utils/logging"
+	"github.com/parithera/plugin-fastqc/src/utils/metrics"
+	"github.com/parithera/plugin-fastqc/src/utils/registration"
+	pluginSettings "github.com/parithera/plugin-fastqc/src/utils/settings"
+	"github.com/parithera/plugin-fastqc/src/utils/tracing"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
@@ -21,13 +24,14 @@ import (
 
 // callback is a function that processes a message received from a plugin dispatcher.
 // It takes the following parameters:
+// - ctx: context.Context, cancelled when the analysis must be interrupted.
 // - args: any, the arguments passed to the callback function.
 // - config: types_plugin.Plugin, the configuration of the plugin.
 // - message: []byte, the message received from the plugin dispatcher.
 //
 // The callback function performs the following steps:
 // 1. Extracts the arguments from the args parameter.
-// 2. Opens a database connection.
+// 2. Reuses the database connection of the arguments.
 // 3. Reads the message and unmarshals it into a dispatcherMessage struct.
 // 4. Starts a timer to measure the execution time.
 // 5. Retrieves the analysis document from the database.
@@ -37,80 +41,84 @@ import (
 // 9. Commits the transaction.
 // 10. Sends the results to the plugins_dispatcher.
 //
-// If any error occurs during the execution of the callback function, it will be logged and the transaction will be aborted.
-func callback(args any, config plugin_db.Plugin, message []byte) {
+// Each step is traced as a child of the span found in ctx.
+//
+// If any error occurs during the execution of the callback function, it will be logged, the transaction will be aborted and the error returned.
+// When ctx is cancelled during the analysis, the returned error wraps context.Canceled and the analysis is left untouched so it can be retried.
+// Once the result is stored, cancellation is ignored: retrying would store the result twice.
+func callback(ctx context.Context, args any, config plugin_db.Plugin, message []byte) (err error) {
+	// Record the outcome of the job once it is known.
+	jobStatus := metrics.JOB_ERROR
+	defer func() {
+		if errors.Is(err, context.Canceled) {
+			jobStatus = metrics.JOB_CANCELLED
+		}
+		metrics.JobsTotal.WithLabelValues(jobStatus).Inc()
+	}()
+
+	logger := logging.FromContext(ctx).With("plugin", config.Name)
+
 	// Get arguments
 	s, ok := args.(Arguments)
 	if !ok {
-		log.Printf("not ok")
-		return
+		logger.Error("invalid callback arguments")
+		return fmt.Errorf("invalid callback arguments")
 	}
 
 	// Read message
 	var dispatcherMessage types_amqp.DispatcherPluginMessage
-	err := json.Unmarshal([]byte(message), &dispatcherMessage)
+	err = json.Unmarshal([]byte(message), &dispatcherMessage)
 	if err != nil {
-		log.Printf("%v", err)
-		return
+		logger.Error("failed to read message", "error", err)
+		return err
 	}
 
+	// Every record logged for this analysis carries its identifiers.
+	ctx = logging.WithLogger(ctx, logger.With(
+		"analysis_id", dispatcherMessage.AnalysisId,
+		"organization_id", dispatcherMessage.OrganizationId,
+	))
+	logger = logging.FromContext(ctx)
+	logger.Info("analysis started")
+
 	// Start timer
 	start := time.Now()
 
-	// Open DB
-	host := os.Getenv("PG_DB_HOST")
-	if host == "" {
-		log.Printf("PG_DB_HOST is not set")
-		return
-	}
-	port := os.Getenv("PG_DB_PORT")
-	if port == "" {
-		log.Printf("PG_DB_PORT is not set")
-		return
-	}
-	user := os.Getenv("PG_DB_USER")
-	if user == "" {
-		log.Printf("PG_DB_USER is not set")
-		return
-	}
-	password := os.Getenv("PG_DB_PASSWORD")
-	if password == "" {
-		log.Printf("PG_DB_PASSWORD is not set")
-		return
-	}
-
-	dsn := "postgres://" + user + ":" + password + "@" + host + ":" + port + "/" + dbhelper.Config.Database.Results + "?sslmode=disable"
-	sqldb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn), pgdriver.WithTimeout(50*time.Second)))
-	db := bun.NewDB(sqldb, pgdialect.New())
-	defer db.Close()
+	db := s.codeclarity
 
-	ctx := context.Background()
 	analysis_document := codeclarity.Analysis{
 		Id: dispatcherMessage.AnalysisId,
 	}
-	err = db.NewSelect().Model(&analysis_document).WherePK().Scan(ctx)
+	selectCtx, selectSpan := tracing.Tracer.Start(ctx, "select analysis")
+	err = db.NewSelect().Model(&analysis_document).WherePK().Scan(selectCtx)
 	if err != nil {
-		log.Printf("%v", err)
-		return
+		tracing.RecordError(selectSpan, err)
+		selectSpan.End()
+		logger.Error("failed to retrieve analysis", "error", err)
+		return err
 	}
+	selectSpan.End()
 
 	// Start analysis
-	result, status, err := startAnalysis(s, dispatcherMessage, config, analysis_document)
+	result, status, err := startAnalysis(ctx, s, dispatcherMessage, config, analysis_document)
 	if err != nil {
-		log.Printf("%v", err)
-		return
+		logger.Error("analysis failed", "error", err)
+		return err
 	}
+	// The result is stored, finish updating the analysis and notifying the dispatcher even when the job is cancelled.
+	ctx = context.WithoutCancel(ctx)
 
 	// Print time elapsed
 	t := time.Now()
 	elapsed := t.Sub(start)
-	log.Println(elapsed)
+	logger.Info("analysis finished", "status", status, "duration", elapsed.Seconds())
+	metrics.QCDuration.Observe(elapsed.Seconds())
 
 	// Send results
 	analysis_document, err = updateAnalysis(result, status, analysis_document, config, start, t, db)
 	if err != nil {
-		log.Printf("%v", err)
-		return
+		logger.Error("failed to update analysis", "error", err)
+		return err
 	}
 
 	// Send results
@@ -119,85 +127,37 @@ func callback(args any, config plugin_db.Plugin, message []byte) {
 		Plugin:     config.Name,
 	}
 	data, _ := json.Marshal(sbom_message)
-	amqp_helper.Send("plugins_dispatcher", data)
-}
-
-// readConfig reads the configuration file and returns a Plugin object and an error.
-// The configuration file is expected to be named "config.json" and should be located in the same directory as the source file.
-// If the file cannot be opened or if there is an error decoding the file, an error is returned.
-// The returned Plugin object contains the parsed configuration values, with the Key field set as the concatenation of the Name and Version fields.
-// If there is an error registering the plugin, an error is returned.
-func readConfig() (plugin_db.Plugin, error) {
-	// Read config file
-	configFile, err := os.Open("config.json")
+	err = send(ctx, s.settings.AMQP, "plugins_dispatcher", data)
 	if err != nil {
-		log.Printf("%v", err)
-		return plugin_db.Plugin{}, err
-	}
-	defer configFile.Close()
-
-	// Decode config file
-	var config plugin_db.Plugin
-	jsonParser := json.NewDecoder(configFile)
-	err = jsonParser.Decode(&config)
-	if err != nil {
-		log.Printf("%v", err)
-		return plugin_db.Plugin{}, err
-	}
-	// config.Key = config.Name + ":" + config.Version
-
-	err = register(config)
-	if err != nil {
-		log.Printf("%v", err)
-		return plugin_db.Plugin{}, err
+		logger.Error("failed to notify the dispatcher", "error", err)
+		return err
 	}
-
-	return config, nil
+	jobStatus = string(status)
+	return nil
 }
 
 // register is a function that registers a plugin configuration in the database.
-// It takes a config parameter of type types_plugin.Plugin, which represents the plugin configuration to be registered.
+// It takes the settings holding the plugin definition to be registered and the database connection parameters.
+// An existing definition is upgraded when the version changes, see registration.Register.
 // The function returns an error if there was an issue with the registration process.
-func register(config plugin_db.Plugin) error {
-	host := os.Getenv("PG_DB_HOST")
-	if host == "" {
-		log.Printf("PG_DB_HOST is not set")
-		return fmt.Errorf("PG_DB_HOST is not set")
-	}
-	port := os.Getenv("PG_DB_PORT")
-	if port == "" {
-		log.Printf("PG_DB_PORT is not set")
-		return fmt.Errorf("PG_DB_PORT is not set")
-	}
-	user := os.Getenv("PG_DB_USER")
-	if user == "" {
-		log.Printf("PG_DB_USER is not set")
-		return fmt.Errorf("PG_DB_USER is not set")
-	}
-	password := os.Getenv("PG_DB_PASSWORD")
-	if password == "" {
-		log.Printf("PG_DB_PASSWORD is not set")
-		return fmt.Errorf("PG_DB_PASSWORD is not set")
-	}
+func register(settings pluginSettings.Settings) error {
+	config := settings.Plugin
 
-	dsn := "postgres://" + user + ":" + password + "@" + host + ":" + port + "/" + dbhelper.Config.Database.Plugins + "?sslmode=disable"
-	sqldb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn), pgdriver.WithTimeout(50*time.Second)))
+	options, err := settings.Database.ConnectorOptions(settings.Database.Plugins)
+	if err != nil {
+		return err
+	}
+	sqldb := sql.OpenDB(pgdriver.NewConnector(append(options, pgdriver.WithTimeout(50*time.Second))...))
 	db := bun.NewDB(sqldb, pgdialect.New())
 	defer db.Close()
 
-	ctx := context.Background()
-	exists, err := db.NewSelect().Model((*plugin_db.Plugin)(nil)).Where("name = ?", config.Name).Exists(ctx)
-
+	action, err := registration.Register(context.Background(), db, config)
 	if err != nil {
-		log.Printf("%v", err)
+		slog.Error("failed to register plugin", "plugin", config.Name, "error", err)
 		return err
 	}
-	if !exists {
-		_, err = db.NewInsert().Model(&config).Exec(ctx)
-		if err != nil {
-			log.Printf("%v", err)
-			return err
-		}
+	if action == registration.OUTDATED {
+		slog.Warn("a newer version of the plugin is already registered", "plugin", config.Name, "version", config.Version)
 	}
 	return nil
 }
@@ -230,7 +190,7 @@ func updateAnalysis(result map[string]any, status codeclarity.AnalysisStatus, an
 				return err
 			})
 			if err != nil {
-				log.Printf("%v", err)
+				slog.Error("error updating analysis", "analysis_id", analysis_document.Id, "plugin", config.Name, "error", err)
 				return codeclarity.Analysis{}, fmt.Errorf("error updating analysis")
 			}
 			return analysis_document, nil